@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"cidoka/token"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -11,6 +12,7 @@ import (
 type Node interface {
 	TokenLiteral() string // returns the literal value of the token
 	String() string       // returns a string representation of the node
+	Pos() token.Position  // returns the position of the node in the source input
 }
 
 // All statement nodes implement this
@@ -25,6 +27,12 @@ type Expression interface {
 	expressionNode() // dummy method to distinguish expressions from statements
 }
 
+// All pattern nodes implement this
+type Pattern interface {
+	Node
+	patternNode() // dummy method to distinguish patterns from expressions and statements
+}
+
 // Program is the root node of every AST
 type Program struct {
 	Statements []Statement // a slice of statements
@@ -37,6 +45,13 @@ func (prog *Program) TokenLiteral() string {
 		return ""
 	}
 }
+func (prog *Program) Pos() token.Position {
+	if len(prog.Statements) > 0 {
+		return prog.Statements[0].Pos()
+	} else {
+		return token.Position{}
+	}
+}
 func (prog *Program) String() string {
 	var out bytes.Buffer
 
@@ -51,20 +66,27 @@ func (prog *Program) String() string {
 // 									Statements
 // ----------------------------------------------------------------------------
 
-// A let statement, e.g. let x = 5;
+// A let or const statement, e.g. let x = 5;, const y = 6; or let [a, b] = pair;
 type LetStatement struct {
-	Token token.Token // token.LET
-	Name  *Identifier // name of the variable
-	Value Expression  // expression that evaluates to the value of the variable
+	Token   token.Token // token.LET or token.CONST
+	Name    *Identifier // name of the variable // or nil when Pattern is set
+	Pattern Pattern     // array or hash pattern the value is destructured into // or nil
+	Value   Expression  // expression that evaluates to the value of the variable
 }
 
 func (letStmt *LetStatement) statementNode()       {}
 func (letStmt *LetStatement) TokenLiteral() string { return letStmt.Token.Literal }
+func (letStmt *LetStatement) Pos() token.Position  { return letStmt.Token.Pos }
+func (letStmt *LetStatement) IsConst() bool        { return letStmt.Token.Type == token.CONST }
 func (letStmt *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(letStmt.TokenLiteral() + " ")
-	out.WriteString(letStmt.Name.String())
+	if letStmt.Pattern != nil {
+		out.WriteString(letStmt.Pattern.String())
+	} else {
+		out.WriteString(letStmt.Name.String())
+	}
 	out.WriteString(" = ")
 
 	if letStmt.Value != nil {
@@ -76,6 +98,30 @@ func (letStmt *LetStatement) String() string {
 	return out.String()
 }
 
+// A named function declaration, e.g. fn add(a, b) { a + b }
+// The name is hoisted to the top of the enclosing scope, so declared functions can call each other
+type FunctionDeclaration struct {
+	Token    token.Token      // token.FUNCTION
+	Name     *Identifier      // name of the function
+	Function *FunctionLiteral // the declared function // its Name is set to the declared name
+}
+
+func (fnDecl *FunctionDeclaration) statementNode()       {}
+func (fnDecl *FunctionDeclaration) TokenLiteral() string { return fnDecl.Token.Literal }
+func (fnDecl *FunctionDeclaration) Pos() token.Position  { return fnDecl.Token.Pos }
+func (fnDecl *FunctionDeclaration) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fnDecl.TokenLiteral() + " ")
+	out.WriteString(fnDecl.Name.String())
+	out.WriteString("(")
+	out.WriteString(fnDecl.Function.parameters())
+	out.WriteString(") ")
+	out.WriteString(fnDecl.Function.Body.String())
+
+	return out.String()
+}
+
 // A return statement, e.g. return 5;
 type ReturnStatement struct {
 	Token       token.Token // token.RETURN
@@ -84,6 +130,7 @@ type ReturnStatement struct {
 
 func (returnStmt *ReturnStatement) statementNode()       {}
 func (returnStmt *ReturnStatement) TokenLiteral() string { return returnStmt.Token.Literal }
+func (returnStmt *ReturnStatement) Pos() token.Position  { return returnStmt.Token.Pos }
 func (returnStmt *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -106,6 +153,7 @@ type ExpressionStatement struct {
 
 func (exprStmt *ExpressionStatement) statementNode()       {}
 func (exprStmt *ExpressionStatement) TokenLiteral() string { return exprStmt.Token.Literal }
+func (exprStmt *ExpressionStatement) Pos() token.Position  { return exprStmt.Token.Pos }
 func (exprStmt *ExpressionStatement) String() string {
 	if exprStmt.Expression != nil {
 		return exprStmt.Expression.String()
@@ -121,6 +169,7 @@ type BlockStatement struct {
 
 func (blockStmt *BlockStatement) statementNode()       {}
 func (blockStmt *BlockStatement) TokenLiteral() string { return blockStmt.Token.Literal }
+func (blockStmt *BlockStatement) Pos() token.Position  { return blockStmt.Token.Pos }
 func (blockStmt *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -138,6 +187,7 @@ func (blockStmt *BlockStatement) String() string {
 // A loop statement, e.g. for (let i = 0; i < 10; i = i + 1) { ... } or while (i < 10) { ... }
 type LoopStatement struct {
 	Token       token.Token     // token.FOR or token.WHILE
+	Label       *Identifier     // label naming the loop for break and continue statements, e.g. outer: // or nil
 	Initializer Statement       // statement that initializes the loop e.g. let i = 0 // or nil
 	Condition   Expression      // expression that evaluates to the condition of the loop e.g. i < 10 // or nil
 	Update      Statement       // expression that updates the loop e.g. i = i + 1 // or nil
@@ -146,9 +196,14 @@ type LoopStatement struct {
 
 func (loop *LoopStatement) statementNode()       {}
 func (loop *LoopStatement) TokenLiteral() string { return loop.Token.Literal }
+func (loop *LoopStatement) Pos() token.Position  { return loop.Token.Pos }
 func (loop *LoopStatement) String() string {
 	var out bytes.Buffer
 
+	if loop.Label != nil {
+		out.WriteString(loop.Label.String() + ": ")
+	}
+
 	out.WriteString("for")
 	out.WriteString(" (")
 
@@ -176,23 +231,75 @@ func (loop *LoopStatement) String() string {
 	return out.String()
 }
 
-// A break statement, e.g. break;
+// A for-in loop, e.g. for (x in [1, 2, 3]) { ... } or for (k, v in hash) { ... }
+type ForInStatement struct {
+	Token    token.Token     // token.FOR
+	Label    *Identifier     // label naming the loop for break and continue statements, e.g. outer: // or nil
+	Index    *Identifier     // first of two loop variables, the index or key of the element // or nil
+	Element  *Identifier     // the element // or the key when iterating over a hash with a single variable
+	Iterable Expression      // expression that evaluates to the array, hash or string to iterate over
+	Body     *BlockStatement // block statement that makes up the body of the loop
+}
+
+func (loop *ForInStatement) statementNode()       {}
+func (loop *ForInStatement) TokenLiteral() string { return loop.Token.Literal }
+func (loop *ForInStatement) Pos() token.Position  { return loop.Token.Pos }
+func (loop *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	if loop.Label != nil {
+		out.WriteString(loop.Label.String() + ": ")
+	}
+
+	out.WriteString("for (")
+	if loop.Index != nil {
+		out.WriteString(loop.Index.String() + ", ")
+	}
+	out.WriteString(loop.Element.String())
+	out.WriteString(" in ")
+	out.WriteString(loop.Iterable.String())
+	out.WriteString(") ")
+
+	out.WriteString("{ ")
+	out.WriteString(loop.Body.String())
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// A break statement, e.g. break; or break outer;
 type BreakStatement struct {
 	Token token.Token // token.BREAK
+	Label *Identifier // label of the enclosing loop to break out of // or nil for the innermost loop
 }
 
 func (breakStmt *BreakStatement) statementNode()       {}
 func (breakStmt *BreakStatement) TokenLiteral() string { return breakStmt.Token.Literal }
-func (breakStmt *BreakStatement) String() string       { return breakStmt.TokenLiteral() }
+func (breakStmt *BreakStatement) Pos() token.Position  { return breakStmt.Token.Pos }
+func (breakStmt *BreakStatement) String() string {
+	if breakStmt.Label != nil {
+		return breakStmt.TokenLiteral() + " " + breakStmt.Label.String()
+	}
+
+	return breakStmt.TokenLiteral()
+}
 
-// A continue statement, e.g. continue;
+// A continue statement, e.g. continue; or continue outer;
 type ContinueStatement struct {
 	Token token.Token // token.CONTINUE
+	Label *Identifier // label of the enclosing loop to continue // or nil for the innermost loop
 }
 
 func (continueStmt *ContinueStatement) statementNode()       {}
 func (continueStmt *ContinueStatement) TokenLiteral() string { return continueStmt.Token.Literal }
-func (continueStmt *ContinueStatement) String() string       { return continueStmt.TokenLiteral() }
+func (continueStmt *ContinueStatement) Pos() token.Position  { return continueStmt.Token.Pos }
+func (continueStmt *ContinueStatement) String() string {
+	if continueStmt.Label != nil {
+		return continueStmt.TokenLiteral() + " " + continueStmt.Label.String()
+	}
+
+	return continueStmt.TokenLiteral()
+}
 
 // ----------------------------------------------------------------------------
 // 								Expressions
@@ -206,17 +313,20 @@ type Identifier struct {
 
 func (ident *Identifier) expressionNode()      {}
 func (ident *Identifier) TokenLiteral() string { return ident.Token.Literal }
+func (ident *Identifier) Pos() token.Position  { return ident.Token.Pos }
 func (ident *Identifier) String() string       { return ident.Value }
 
 type AssignExpression struct {
 	Token    token.Token // token.IDENT
 	Left     Expression  // left expression to be assigned
+	Pattern  Pattern     // pattern Left was converted to when it is an array or hash literal, e.g. [a, b] = [b, a] // or nil
 	Operator string      // one of the assignment operator tokens
 	Right    Expression  // right expression to be assigned
 }
 
 func (assignExpr *AssignExpression) expressionNode()      {}
 func (assignExpr *AssignExpression) TokenLiteral() string { return "" }
+func (assignExpr *AssignExpression) Pos() token.Position  { return assignExpr.Token.Pos }
 func (assignExpr *AssignExpression) String() string {
 	var out bytes.Buffer
 
@@ -235,6 +345,7 @@ type IntegerLiteral struct {
 
 func (intLit *IntegerLiteral) expressionNode()      {}
 func (intLit *IntegerLiteral) TokenLiteral() string { return intLit.Token.Literal }
+func (intLit *IntegerLiteral) Pos() token.Position  { return intLit.Token.Pos }
 func (intLit *IntegerLiteral) String() string       { return intLit.Token.Literal }
 
 // A float literal expression, e.g. 5.5
@@ -245,6 +356,7 @@ type FloatLiteral struct {
 
 func (floatLit *FloatLiteral) expressionNode()      {}
 func (floatLit *FloatLiteral) TokenLiteral() string { return floatLit.Token.Literal }
+func (floatLit *FloatLiteral) Pos() token.Position  { return floatLit.Token.Pos }
 func (floatLit *FloatLiteral) String() string       { return floatLit.Token.Literal }
 
 // A boolean expression, e.g. true or false
@@ -255,8 +367,19 @@ type Boolean struct {
 
 func (boolExpr *Boolean) expressionNode()      {}
 func (boolExpr *Boolean) TokenLiteral() string { return boolExpr.Token.Literal }
+func (boolExpr *Boolean) Pos() token.Position  { return boolExpr.Token.Pos }
 func (boolExpr *Boolean) String() string       { return boolExpr.Token.Literal }
 
+// The null literal
+type NullLiteral struct {
+	Token token.Token // token.NULL
+}
+
+func (null *NullLiteral) expressionNode()      {}
+func (null *NullLiteral) TokenLiteral() string { return null.Token.Literal }
+func (null *NullLiteral) Pos() token.Position  { return null.Token.Pos }
+func (null *NullLiteral) String() string       { return null.Token.Literal }
+
 // A string literal expression, e.g. "foobar"
 type StringLiteral struct {
 	Token token.Token // token.STRING
@@ -265,8 +388,37 @@ type StringLiteral struct {
 
 func (strLit *StringLiteral) expressionNode()      {}
 func (strLit *StringLiteral) TokenLiteral() string { return strLit.Token.Literal }
+func (strLit *StringLiteral) Pos() token.Position  { return strLit.Token.Pos }
 func (strLit *StringLiteral) String() string       { return strLit.Token.Literal }
 
+// An interpolated string, e.g. "hello ${name}!"
+type InterpolatedString struct {
+	Token token.Token  // token.INTERP_HEAD
+	Parts []Expression // text parts (*StringLiteral) and embedded expressions, in order
+}
+
+func (interp *InterpolatedString) expressionNode()      {}
+func (interp *InterpolatedString) TokenLiteral() string { return interp.Token.Literal }
+func (interp *InterpolatedString) Pos() token.Position  { return interp.Token.Pos }
+func (interp *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for _, part := range interp.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(text.Value)
+			continue
+		}
+
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
+
 // A prefix expression, e.g. !5 or -15
 type PrefixExpression struct {
 	Token    token.Token // the prefix token, e.g. token.BANG or token.MINUS
@@ -276,6 +428,7 @@ type PrefixExpression struct {
 
 func (prefixExpr *PrefixExpression) expressionNode()      {}
 func (prefixExpr *PrefixExpression) TokenLiteral() string { return prefixExpr.Token.Literal }
+func (prefixExpr *PrefixExpression) Pos() token.Position  { return prefixExpr.Token.Pos }
 func (prefixExpr *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -297,6 +450,7 @@ type InfixExpression struct {
 
 func (infixExpr *InfixExpression) expressionNode()      {}
 func (infixExpr *InfixExpression) TokenLiteral() string { return infixExpr.Token.Literal }
+func (infixExpr *InfixExpression) Pos() token.Position  { return infixExpr.Token.Pos }
 func (infixExpr *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -309,6 +463,35 @@ func (infixExpr *InfixExpression) String() string {
 	return out.String()
 }
 
+// A range expression, e.g. 0..10 or 10..0:-2
+type RangeExpression struct {
+	Token token.Token // token.RANGE
+	Start Expression  // expression that evaluates to the first integer of the range
+	End   Expression  // expression that evaluates to the end of the range, which is excluded
+	Step  Expression  // expression that evaluates to the difference between consecutive integers // or nil
+}
+
+func (rangeExpr *RangeExpression) expressionNode()      {}
+func (rangeExpr *RangeExpression) TokenLiteral() string { return rangeExpr.Token.Literal }
+func (rangeExpr *RangeExpression) Pos() token.Position  { return rangeExpr.Token.Pos }
+func (rangeExpr *RangeExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(rangeExpr.Start.String())
+	out.WriteString("..")
+	out.WriteString(rangeExpr.End.String())
+
+	if rangeExpr.Step != nil {
+		out.WriteString(":")
+		out.WriteString(rangeExpr.Step.String())
+	}
+
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // A postifx expression, e.g. 5++
 type PostfixExpression struct {
 	Token    token.Token // the postfix token, e.g. token.INCREMENT
@@ -318,6 +501,7 @@ type PostfixExpression struct {
 
 func (postfixExpr *PostfixExpression) expressionNode()      {}
 func (postfixExpr *PostfixExpression) TokenLiteral() string { return postfixExpr.Token.Literal }
+func (postfixExpr *PostfixExpression) Pos() token.Position  { return postfixExpr.Token.Pos }
 func (postfixExpr *PostfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -339,6 +523,7 @@ type IfExpression struct {
 
 func (ifExpr *IfExpression) expressionNode()      {}
 func (ifExpr *IfExpression) TokenLiteral() string { return ifExpr.Token.Literal }
+func (ifExpr *IfExpression) Pos() token.Position  { return ifExpr.Token.Pos }
 func (ifExpr *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -359,41 +544,59 @@ func (ifExpr *IfExpression) String() string {
 type FunctionLiteral struct {
 	Token      token.Token     // token.FUNCTION
 	Parameters []*Identifier   // slice of identifiers that make up the parameters of the function
+	Defaults   []Expression    // default values of the parameters // Defaults[i] belongs to Parameters[i] // nil for required ones
+	Rest       *Identifier     // rest parameter collecting the extra arguments, e.g. ...args // or nil
 	Body       *BlockStatement // block statement that makes up the body of the function
 	Name       string          // name of the function // should be the same as the token literal // or ""
 }
 
 func (funcLit *FunctionLiteral) expressionNode()      {}
 func (funcLit *FunctionLiteral) TokenLiteral() string { return funcLit.Token.Literal }
+func (funcLit *FunctionLiteral) Pos() token.Position  { return funcLit.Token.Pos }
 func (funcLit *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, param := range funcLit.Parameters {
-		params = append(params, param.String())
-	}
-
 	out.WriteString(funcLit.TokenLiteral())
 	if funcLit.Name != "" {
 		out.WriteString(fmt.Sprintf("<%s>", funcLit.Name))
 	}
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(funcLit.parameters())
 	out.WriteString(") ")
 	out.WriteString(funcLit.Body.String())
 
 	return out.String()
 }
 
+// Returns the comma-separated parameters of the function, with their default values and the rest parameter
+func (funcLit *FunctionLiteral) parameters() string {
+	params := []string{}
+	for i, param := range funcLit.Parameters {
+		if i < len(funcLit.Defaults) && funcLit.Defaults[i] != nil {
+			params = append(params, param.String()+" = "+funcLit.Defaults[i].String())
+		} else {
+			params = append(params, param.String())
+		}
+	}
+
+	if funcLit.Rest != nil {
+		params = append(params, "..."+funcLit.Rest.String())
+	}
+
+	return strings.Join(params, ", ")
+}
+
 // A function call expression, e.g. add(1, 2)
 type CallExpression struct {
-	Token     token.Token  // token.LPAREN '('
-	Function  Expression   // Identifier or FunctionLiteral
-	Arguments []Expression // slice of expressions that make up the arguments of the function
+	Token     token.Token        // token.LPAREN '('
+	Function  Expression         // Identifier or FunctionLiteral
+	Arguments []Expression       // slice of expressions that make up the positional arguments of the function // may contain spreads
+	Keywords  []*KeywordArgument // keyword arguments, e.g. port: 5432 // always after the positional ones
 }
 
 func (callExpr *CallExpression) expressionNode()      {}
 func (callExpr *CallExpression) TokenLiteral() string { return callExpr.Token.Literal }
+func (callExpr *CallExpression) Pos() token.Position  { return callExpr.Token.Pos }
 func (callExpr *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -402,6 +605,10 @@ func (callExpr *CallExpression) String() string {
 		args = append(args, arg.String())
 	}
 
+	for _, arg := range callExpr.Keywords {
+		args = append(args, arg.String())
+	}
+
 	out.WriteString(callExpr.Function.String())
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
@@ -410,14 +617,41 @@ func (callExpr *CallExpression) String() string {
 	return out.String()
 }
 
+// A keyword argument of a call expression, e.g. port: 5432 in connect(host: "db", port: 5432)
+type KeywordArgument struct {
+	Token token.Token // token.IDENT, the name of the parameter
+	Name  *Identifier // parameter the argument is passed to
+	Value Expression  // value of the argument
+}
+
+func (kwArg *KeywordArgument) TokenLiteral() string { return kwArg.Token.Literal }
+func (kwArg *KeywordArgument) Pos() token.Position  { return kwArg.Token.Pos }
+func (kwArg *KeywordArgument) String() string {
+	return kwArg.Name.String() + ": " + kwArg.Value.String()
+}
+
+// A spread element, e.g. ...xs in [...xs, 1], {...defaults} or f(...args)
+type SpreadExpression struct {
+	Token token.Token // token.ELLIPSIS '...'
+	Value Expression  // expression whose elements (or pairs, in a hash literal) are spread
+}
+
+func (spread *SpreadExpression) expressionNode()      {}
+func (spread *SpreadExpression) TokenLiteral() string { return spread.Token.Literal }
+func (spread *SpreadExpression) Pos() token.Position  { return spread.Token.Pos }
+func (spread *SpreadExpression) String() string {
+	return "..." + spread.Value.String()
+}
+
 // An array literal, e.g. [1, 2, 3]
 type ArrayLiteral struct {
 	Token    token.Token  // token.LBRACKET '['
-	Elements []Expression // slice of expressions that make up the elements of the array
+	Elements []Expression // slice of expressions that make up the elements of the array // may contain spreads
 }
 
 func (arrLit *ArrayLiteral) expressionNode()      {}
 func (arrLit *ArrayLiteral) TokenLiteral() string { return arrLit.Token.Literal }
+func (arrLit *ArrayLiteral) Pos() token.Position  { return arrLit.Token.Pos }
 func (arrLit *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -433,20 +667,83 @@ func (arrLit *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// The for clause of a comprehension with its optional condition, e.g. for k, v in h if v > 0
+type ComprehensionClause struct {
+	Token     token.Token // token.FOR
+	Index     *Identifier // first of two loop variables, as in a for-in loop // or nil
+	Element   *Identifier // the element // or the key when iterating over a hash with a single variable
+	Iterable  Expression  // expression that evaluates to the value to iterate over
+	Condition Expression  // iterations for which it is falsy add nothing // or nil
+}
+
+func (clause *ComprehensionClause) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for ")
+	if clause.Index != nil {
+		out.WriteString(clause.Index.String() + ", ")
+	}
+	out.WriteString(clause.Element.String())
+	out.WriteString(" in ")
+	out.WriteString(clause.Iterable.String())
+
+	if clause.Condition != nil {
+		out.WriteString(" if ")
+		out.WriteString(clause.Condition.String())
+	}
+
+	return out.String()
+}
+
+// An array comprehension, e.g. [x * 2 for x in xs if x > 0]
+type ArrayComprehension struct {
+	Token   token.Token          // token.LBRACKET '['
+	Element Expression           // expression evaluated on every iteration to make an element // or a spread adding several
+	Clause  *ComprehensionClause // loop over the iterable
+}
+
+func (arrComp *ArrayComprehension) expressionNode()      {}
+func (arrComp *ArrayComprehension) TokenLiteral() string { return arrComp.Token.Literal }
+func (arrComp *ArrayComprehension) Pos() token.Position  { return arrComp.Token.Pos }
+func (arrComp *ArrayComprehension) String() string {
+	return "[" + arrComp.Element.String() + " " + arrComp.Clause.String() + "]"
+}
+
+// A hash comprehension, e.g. {k: v * 2 for k, v in h}
+type HashComprehension struct {
+	Token  token.Token          // token.LBRACE '{'
+	Key    Expression           // expression evaluated on every iteration to make a key
+	Value  Expression           // expression evaluated on every iteration to make the value of the key
+	Clause *ComprehensionClause // loop over the iterable
+}
+
+func (hashComp *HashComprehension) expressionNode()      {}
+func (hashComp *HashComprehension) TokenLiteral() string { return hashComp.Token.Literal }
+func (hashComp *HashComprehension) Pos() token.Position  { return hashComp.Token.Pos }
+func (hashComp *HashComprehension) String() string {
+	return "{" + hashComp.Key.String() + ":" + hashComp.Value.String() + " " + hashComp.Clause.String() + "}"
+}
+
 // A hash literal, e.g. {"one": 1, "two": 2}
 type HashLiteral struct {
-	Token token.Token               // token.LBRACE '{'
-	Pairs map[Expression]Expression // map of key-value pairs that make up the hashmap
+	Token   token.Token               // token.LBRACE '{'
+	Pairs   map[Expression]Expression // map of key-value pairs that make up the hashmap
+	Spreads []*SpreadExpression       // hashes spread among the pairs, e.g. ...defaults // ordered with them by position
 }
 
 func (hashLit *HashLiteral) expressionNode()      {}
 func (hashLit *HashLiteral) TokenLiteral() string { return hashLit.Token.Literal }
+func (hashLit *HashLiteral) Pos() token.Position  { return hashLit.Token.Pos }
 func (hashLit *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hashLit.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, entry := range hashLit.Entries() {
+		if spread, ok := entry.(*SpreadExpression); ok {
+			pairs = append(pairs, spread.String())
+		} else {
+			pairs = append(pairs, entry.String()+":"+hashLit.Pairs[entry].String())
+		}
 	}
 
 	out.WriteString("{")
@@ -456,6 +753,21 @@ func (hashLit *HashLiteral) String() string {
 	return out.String()
 }
 
+// Returns the keys of the pairs and the spreads of the hash literal in source order
+// A pair or a spread overrides the keys set before it, so this is the order they are evaluated in
+func (hashLit *HashLiteral) Entries() []Expression {
+	entries := []Expression{}
+	for key := range hashLit.Pairs {
+		entries = append(entries, key)
+	}
+	for _, spread := range hashLit.Spreads {
+		entries = append(entries, spread)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Pos().Offset < entries[j].Pos().Offset })
+	return entries
+}
+
 // An index expression, e.g. array[1]
 type IndexExpression struct {
 	Token token.Token // token.LBRACKET '['
@@ -465,6 +777,7 @@ type IndexExpression struct {
 
 func (indexExpr *IndexExpression) expressionNode()      {}
 func (indexExpr *IndexExpression) TokenLiteral() string { return indexExpr.Token.Literal }
+func (indexExpr *IndexExpression) Pos() token.Position  { return indexExpr.Token.Pos }
 func (indexExpr *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -476,3 +789,176 @@ func (indexExpr *IndexExpression) String() string {
 
 	return out.String()
 }
+
+// A slice expression, e.g. array[1:3], str[:-1] or array[2:]
+type SliceExpression struct {
+	Token token.Token // token.LBRACKET '['
+	Left  Expression  // expression to be sliced e.g. array literal
+	Start Expression  // expression that evaluates to the first index of the slice // or nil
+	End   Expression  // expression that evaluates to the index the slice stops before // or nil
+}
+
+func (sliceExpr *SliceExpression) expressionNode()      {}
+func (sliceExpr *SliceExpression) TokenLiteral() string { return sliceExpr.Token.Literal }
+func (sliceExpr *SliceExpression) Pos() token.Position  { return sliceExpr.Token.Pos }
+func (sliceExpr *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(sliceExpr.Left.String())
+	out.WriteString("[")
+	if sliceExpr.Start != nil {
+		out.WriteString(sliceExpr.Start.String())
+	}
+	out.WriteString(":")
+	if sliceExpr.End != nil {
+		out.WriteString(sliceExpr.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// A match expression, e.g. match (x) { 1 => "one", [a, b] => a + b, _ => null }
+type MatchExpression struct {
+	Token   token.Token // token.MATCH
+	Subject Expression  // expression that evaluates to the value being matched
+	Arms    []*MatchArm // arms tried in order, the first matching one is evaluated
+}
+
+func (matchExpr *MatchExpression) expressionNode()      {}
+func (matchExpr *MatchExpression) TokenLiteral() string { return matchExpr.Token.Literal }
+func (matchExpr *MatchExpression) Pos() token.Position  { return matchExpr.Token.Pos }
+func (matchExpr *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range matchExpr.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(matchExpr.Subject.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// A single arm of a match expression, e.g. [a, b] if a > b => a
+type MatchArm struct {
+	Token   token.Token     // first token of the pattern
+	Pattern Pattern         // pattern the subject is matched against
+	Guard   Expression      // condition that must also hold for the arm to be taken // or nil
+	Body    *BlockStatement // block statement evaluated when the arm is taken
+}
+
+func (arm *MatchArm) TokenLiteral() string { return arm.Token.Literal }
+func (arm *MatchArm) Pos() token.Position  { return arm.Token.Pos }
+func (arm *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(arm.Pattern.String())
+
+	if arm.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(arm.Guard.String())
+	}
+
+	out.WriteString(" => ")
+	out.WriteString(arm.Body.String())
+
+	return out.String()
+}
+
+// ----------------------------------------------------------------------------
+// 									Patterns
+// ----------------------------------------------------------------------------
+
+// A literal pattern, e.g. 1, -2.5, "foo", true or null
+type LiteralPattern struct {
+	Token token.Token // first token of the literal
+	Value Expression  // literal the value is compared against
+}
+
+func (litPat *LiteralPattern) patternNode()         {}
+func (litPat *LiteralPattern) TokenLiteral() string { return litPat.Token.Literal }
+func (litPat *LiteralPattern) Pos() token.Position  { return litPat.Token.Pos }
+func (litPat *LiteralPattern) String() string       { return litPat.Value.String() }
+
+// The wildcard pattern _, which matches any value without binding it
+type WildcardPattern struct {
+	Token token.Token // token.IDENT '_'
+}
+
+func (wildPat *WildcardPattern) patternNode()         {}
+func (wildPat *WildcardPattern) TokenLiteral() string { return wildPat.Token.Literal }
+func (wildPat *WildcardPattern) Pos() token.Position  { return wildPat.Token.Pos }
+func (wildPat *WildcardPattern) String() string       { return wildPat.Token.Literal }
+
+// A binding pattern, e.g. x, which matches any value and binds it to the name
+type BindingPattern struct {
+	Token token.Token // token.IDENT
+	Name  *Identifier // name the value is bound to
+}
+
+func (bindPat *BindingPattern) patternNode()         {}
+func (bindPat *BindingPattern) TokenLiteral() string { return bindPat.Token.Literal }
+func (bindPat *BindingPattern) Pos() token.Position  { return bindPat.Token.Pos }
+func (bindPat *BindingPattern) String() string       { return bindPat.Name.String() }
+
+// An array pattern, e.g. [a, 2, _] or [first, ...rest], which matches arrays of the same length
+// or, with a rest element, arrays with at least as many elements
+type ArrayPattern struct {
+	Token    token.Token // token.LBRACKET '['
+	Elements []Pattern   // patterns the elements are matched against, in order
+	Rest     Pattern     // binding or wildcard pattern the remaining elements are matched against // or nil
+}
+
+func (arrPat *ArrayPattern) patternNode()         {}
+func (arrPat *ArrayPattern) TokenLiteral() string { return arrPat.Token.Literal }
+func (arrPat *ArrayPattern) Pos() token.Position  { return arrPat.Token.Pos }
+func (arrPat *ArrayPattern) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range arrPat.Elements {
+		elements = append(elements, el.String())
+	}
+
+	if arrPat.Rest != nil {
+		elements = append(elements, "..."+arrPat.Rest.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// A hash pattern, e.g. {"type": t}, which matches hashes containing all of the keys
+type HashPattern struct {
+	Token  token.Token  // token.LBRACE '{'
+	Keys   []Expression // literal keys, in source order
+	Values []Pattern    // patterns the values are matched against // Values[i] belongs to Keys[i]
+}
+
+func (hashPat *HashPattern) patternNode()         {}
+func (hashPat *HashPattern) TokenLiteral() string { return hashPat.Token.Literal }
+func (hashPat *HashPattern) Pos() token.Position  { return hashPat.Token.Pos }
+func (hashPat *HashPattern) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for i, key := range hashPat.Keys {
+		pairs = append(pairs, key.String()+":"+hashPat.Values[i].String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -58,6 +58,13 @@ func main() {
 	p := parser.New(l)
 	program := p.ParseProgram()
 
+	if errors := p.ParseErrors(); len(errors) != 0 {
+		for _, err := range errors {
+			fmt.Printf("parser error: %s\n", err)
+		}
+		return
+	}
+
 	if *engine == "vm" {
 		comp := compiler.New()
 		err := comp.Compile(program)
@@ -2,6 +2,7 @@ package code
 
 import (
 	"bytes"
+	"cidoka/token"
 	"encoding/binary"
 	"fmt"
 )
@@ -51,6 +52,25 @@ func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
 	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
 }
 
+// Maps the offset of each instruction to the position of the source code it was compiled from
+type SourceMap map[int]token.Position
+
+// Returns the source position of the instruction that contains the given offset
+func (sm SourceMap) Lookup(offset int) (token.Position, bool) {
+	best := -1
+	for start := range sm {
+		if start <= offset && start > best {
+			best = start
+		}
+	}
+
+	if best < 0 {
+		return token.Position{}, false
+	}
+
+	return sm[best], true
+}
+
 // Opcode is a byte
 type Opcode byte
 
@@ -68,6 +88,15 @@ const (
 	OpMul // Pop the top two elements of the stack, multiply them and push the result to the stack
 	OpDiv // Pop the top two elements of the stack, divide them and push the result to the stack
 	OpMod // Pop the top two elements of the stack, modulo them and push the result to the stack
+	OpPow // Pop the top two elements of the stack, raise the first to the power of the second and push the result to the stack
+
+	// Bitwise Opcodes
+
+	OpBitAnd // Pop the top two elements of the stack, perform a bitwise AND and push the result to the stack
+	OpBitOr  // Pop the top two elements of the stack, perform a bitwise OR and push the result to the stack
+	OpBitXor // Pop the top two elements of the stack, perform a bitwise XOR and push the result to the stack
+	OpShl    // Pop the top two elements of the stack, shift the second left by the first and push the result to the stack
+	OpShr    // Pop the top two elements of the stack, shift the second right by the first and push the result to the stack
 
 	// Boolean Opcodes
 
@@ -82,6 +111,7 @@ const (
 	OpGreaterOrEqual // Pop the top two elements of the stack and compare for greater or equal, push the result to the stack
 	OpLessThan       // Pop the top two elements of the stack and compare for less than, push the result to the stack
 	OpLessOrEqual    // Pop the top two elements of the stack and compare for less or equal, push the result to the stack
+	OpIn             // Pop the top two elements of the stack, push whether the second is in the first
 
 	// Logical Opcodes
 	OpAnd // Pop the top two elements of the stack and perform a boolean AND, push the result to the stack
@@ -89,13 +119,16 @@ const (
 
 	// Prefix Opcodes
 
-	OpMinus // Pop the top element of the stack, perform arithmetic negation and push the result to the stack
-	OpBang  // Pop the top element of the stack, perform boolean negation and push the result to the stack
+	OpMinus  // Pop the top element of the stack, perform arithmetic negation and push the result to the stack
+	OpBang   // Pop the top element of the stack, perform boolean negation and push the result to the stack
+	OpBitNot // Pop the top element of the stack, perform bitwise negation and push the result to the stack
 
 	// Jump Opcodes
 
 	OpJumpNotTruthy // Pop the top element of the stack and jump to a specific position if it is not truthy
 	OpJump          // Jump to a specific position
+	OpJumpNotNull   // Jump to a specific position if the top element of the stack is not null, leaving it there // pops it otherwise
+	OpJumpArgPassed // Jump to a specific position if the argument at index n was passed to the current function
 
 	// Variable Opcodes
 
@@ -113,12 +146,29 @@ const (
 	OpHash     // Push a hash to the stack made from the n elements below it // n is even
 	OpSetIndex // Pop the top three elements of the stack, using the first as the value and the second as an index to the third
 	OpGetIndex // Pop the top two elements of the stack, using the first as an index to the second, push the result to the stack
+	OpSlice    // Pop the top three elements of the stack, push the slice of the third from the second up to the first
+
+	OpArraySpread // Pop the top element of the stack and append its elements to the array below it
+	OpHashSpread  // Pop the top element of the stack and copy its pairs to the hash below it
+
+	OpMatchArray // Pop the top element of the stack, push whether it is an array of n elements // or at least n if the second operand is 1
+	OpMatchHash  // Pop the top n+1 elements of the stack, push whether the last is a hash containing the n keys above it
+	OpArrayRest  // Pop the top element of the stack, push an array of its elements from index n on
+
+	OpInterpolate // Push a string to the stack made by stringifying and joining the n elements below it
+	OpRange       // Push a range to the stack made from the n elements below it, the start, the end and an optional step
 
 	// Function Opcodes
 
-	OpClosure    // Push a closure to the stack
-	OpGetBuiltin // Push a builtin function to the stack
-	OpCall       // Call top n+1 elements of the stack as a function // n is the number of arguments // last element is the function
+	OpClosure      // Push a closure to the stack
+	OpHoistClosure // Push an empty closure to the stack // a hoisted function, filled in by OpFillClosure when it's declared
+	OpFillClosure  // Pop an empty closure and a closure below it, fill the empty closure with the closure and push it
+	OpGetBuiltin   // Push a builtin function to the stack
+	OpCall         // Call top n+1 elements of the stack as a function // n is the number of arguments // last element is the function
+	OpCallKeywords // Like OpCall, with the values of the keyword arguments named by constant k above the n positional arguments
+
+	OpCallSpread         // Like OpCall, with an array of the positional arguments instead of n of them // a call with spread arguments
+	OpCallSpreadKeywords // Like OpCallKeywords, with an array of the positional arguments instead of n of them
 
 	OpCurrentClosure // Push the current closure as a variable // recursion
 	OpSetFree        // Pop the top element of the stack and set it to a free scope variable
@@ -129,8 +179,12 @@ const (
 
 	// Loop Opcodes
 
-	OpLoop  // Push a loop onto the stack // pops after the loop
-	OpBreak // Break out of a loop // pops a loop
+	OpLoop          // Push a loop onto the stack // pops after the loop
+	OpBreak         // Break out of a loop // pops a loop
+	OpBreakOuter    // Break out of the loop n levels above the current one // pops it and the n loops inside it
+	OpContinueOuter // Continue the loop n levels above the current one // pops the n loops inside it
+	OpIter          // Pop an iterable object and push an iterator over it
+	OpIterNext      // Pop an iterator, push the values of the n variables of a for-in loop and true, or false once it's exhausted
 )
 
 // Opcode definitions
@@ -154,6 +208,15 @@ var definitions = map[Opcode]*Definition{
 	OpMul: {"OpMul", []int{}}, // No operands, 1 byte in total
 	OpDiv: {"OpDiv", []int{}}, // No operands, 1 byte in total
 	OpMod: {"OpMod", []int{}}, // No operands, 1 byte in total
+	OpPow: {"OpPow", []int{}}, // No operands, 1 byte in total
+
+	// Bitwise Opcodes
+
+	OpBitAnd: {"OpBitAnd", []int{}}, // No operands, 1 byte in total
+	OpBitOr:  {"OpBitOr", []int{}},  // No operands, 1 byte in total
+	OpBitXor: {"OpBitXor", []int{}}, // No operands, 1 byte in total
+	OpShl:    {"OpShl", []int{}},    // No operands, 1 byte in total
+	OpShr:    {"OpShr", []int{}},    // No operands, 1 byte in total
 
 	// Boolean Opcodes
 
@@ -168,6 +231,7 @@ var definitions = map[Opcode]*Definition{
 	OpGreaterOrEqual: {"OpGreaterOrEqual", []int{}}, // No operands, 1 byte in total
 	OpLessThan:       {"OpLessThan", []int{}},       // No operands, 1 byte in total
 	OpLessOrEqual:    {"OpLessOrEqual", []int{}},    // No operands, 1 byte in total
+	OpIn:             {"OpIn", []int{}},             // No operands, 1 byte in total
 
 	// Logical Opcodes
 	OpAnd: {"OpAnd", []int{}}, // No operands, 1 byte in total
@@ -175,13 +239,16 @@ var definitions = map[Opcode]*Definition{
 
 	// Prefix Opcodes
 
-	OpMinus: {"OpMinus", []int{}}, // No operands, 1 byte in total
-	OpBang:  {"OpBang", []int{}},  // No operands, 1 byte in total
+	OpMinus:  {"OpMinus", []int{}},  // No operands, 1 byte in total
+	OpBang:   {"OpBang", []int{}},   // No operands, 1 byte in total
+	OpBitNot: {"OpBitNot", []int{}}, // No operands, 1 byte in total
 
 	// Jump Opcodes
 
-	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}}, // Single operand of 2 bytes, 3 bytes in total
-	OpJump:          {"OpJump", []int{2}},          // Single operand of 2 bytes, 3 bytes in total
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},    // Single operand of 2 bytes, 3 bytes in total
+	OpJump:          {"OpJump", []int{2}},             // Single operand of 2 bytes, 3 bytes in total
+	OpJumpNotNull:   {"OpJumpNotNull", []int{2}},      // Single operand of 2 bytes, 3 bytes in total
+	OpJumpArgPassed: {"OpJumpArgPassed", []int{2, 1}}, // Two operands of 2 and 1 bytes, 4 bytes in total
 
 	// Variable Opcodes
 
@@ -199,12 +266,29 @@ var definitions = map[Opcode]*Definition{
 	OpHash:     {"OpHash", []int{2}},    // Single operand of 2 bytes, 3 bytes in total
 	OpSetIndex: {"OpSetIndex", []int{}}, // No operands, 1 byte in total
 	OpGetIndex: {"OpGetIndex", []int{}}, // No operands, 1 byte in total
+	OpSlice:    {"OpSlice", []int{}},    // No operands, 1 byte in total
+
+	OpArraySpread: {"OpArraySpread", []int{}}, // No operands, 1 byte in total
+	OpHashSpread:  {"OpHashSpread", []int{}},  // No operands, 1 byte in total
+
+	OpMatchArray: {"OpMatchArray", []int{2, 1}}, // Two operands of 2 and 1 bytes, 4 bytes in total
+	OpMatchHash:  {"OpMatchHash", []int{2}},     // Single operand of 2 bytes, 3 bytes in total
+	OpArrayRest:  {"OpArrayRest", []int{2}},     // Single operand of 2 bytes, 3 bytes in total
+
+	OpInterpolate: {"OpInterpolate", []int{2}}, // Single operand of 2 bytes, 3 bytes in total
+	OpRange:       {"OpRange", []int{1}},       // Single operand of 1 byte, 2 bytes in total
 
 	// Function Opcodes
 
-	OpClosure:    {"OpClosure", []int{2, 1}}, // Two operands of 2 and 1 bytes, 4 bytes in total
-	OpGetBuiltin: {"OpGetBuiltin", []int{1}}, // Single operand of 1 byte, 2 bytes in total
-	OpCall:       {"OpCall", []int{1}},       // Single operand of 1 byte, 2 bytes in total
+	OpClosure:      {"OpClosure", []int{2, 1}},      // Two operands of 2 and 1 bytes, 4 bytes in total
+	OpHoistClosure: {"OpHoistClosure", []int{}},     // No operands, 1 byte in total
+	OpFillClosure:  {"OpFillClosure", []int{}},      // No operands, 1 byte in total
+	OpGetBuiltin:   {"OpGetBuiltin", []int{1}},      // Single operand of 1 byte, 2 bytes in total
+	OpCall:         {"OpCall", []int{1}},            // Single operand of 1 byte, 2 bytes in total
+	OpCallKeywords: {"OpCallKeywords", []int{1, 2}}, // Two operands of 1 and 2 bytes, 4 bytes in total
+
+	OpCallSpread:         {"OpCallSpread", []int{}},          // No operands, 1 byte in total
+	OpCallSpreadKeywords: {"OpCallSpreadKeywords", []int{2}}, // Single operand of 2 bytes, 3 bytes in total
 
 	OpReturnValue: {"OpReturnValue", []int{}}, // No operands, 1 byte in total
 	OpReturn:      {"OpReturn", []int{}},      // No operands, 1 byte in total
@@ -215,8 +299,12 @@ var definitions = map[Opcode]*Definition{
 
 	// Loop Opcodes
 
-	OpLoop:  {"OpLoop", []int{2}}, // Single operand of 2 bytes, 3 bytes in total
-	OpBreak: {"OpBreak", []int{}}, // No operands, 1 byte in total
+	OpLoop:          {"OpLoop", []int{2}},          // Single operand of 2 bytes, 3 bytes in total
+	OpBreak:         {"OpBreak", []int{}},          // No operands, 1 byte in total
+	OpBreakOuter:    {"OpBreakOuter", []int{1}},    // Single operand of 1 byte, 2 bytes in total
+	OpContinueOuter: {"OpContinueOuter", []int{1}}, // Single operand of 1 byte, 2 bytes in total
+	OpIter:          {"OpIter", []int{}},           // No operands, 1 byte in total
+	OpIterNext:      {"OpIterNext", []int{1}},      // Single operand of 1 byte, 2 bytes in total
 }
 
 // Returns the Definition of the opcode
@@ -14,6 +14,8 @@ func TestMake(t *testing.T) {
 		{OpAdd, []int{}, []byte{byte(OpAdd)}},
 		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
 		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpCallKeywords, []int{2, 65534}, []byte{byte(OpCallKeywords), 2, 255, 254}},
+		{OpBreakOuter, []int{1}, []byte{byte(OpBreakOuter), 1}},
 	}
 
 	for _, tt := range tests {
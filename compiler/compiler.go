@@ -6,15 +6,21 @@ import (
 	"cidoka/object"
 	"cidoka/token"
 	"fmt"
-	"sort"
 )
 
-var (
-	loopContinuePos = []int{}
+// Names of the hidden variables holding the subject of a match expression, a destructured value, the
+// iterator of a for-in loop and the array or hash a comprehension builds
+// They are not valid identifiers, so they can't clash with user names
+const (
+	matchSubjectName      = "@match"
+	destructuredValueName = "@destructured"
+	iteratorName          = "@iterator"
+	accumulatorName       = "@accumulator"
 )
 
 type Bytecode struct {
 	Instructions code.Instructions
+	SourceMap    code.SourceMap
 	Constants    []object.Object
 }
 
@@ -25,10 +31,24 @@ type EmittedInstruction struct {
 
 type CompilationScope struct {
 	instructions        code.Instructions
+	sourceMap           code.SourceMap
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
 }
 
+// A loop being compiled, the target of the break and continue statements in its body
+type loopScope struct {
+	label         string // or empty for a loop without a label
+	continueJumps []int  // positions of the jumps emitted by its continue statements, patched once the loop is compiled
+}
+
+// A symbol shadowed by a name bound in a pattern, restored once the name goes out of scope
+type shadowedSymbol struct {
+	name   string
+	symbol Symbol
+	ok     bool // false if the name wasn't defined in the table before
+}
+
 type Compiler struct {
 	constants []object.Object
 
@@ -36,11 +56,16 @@ type Compiler struct {
 
 	scopes     []CompilationScope
 	scopeIndex int
+
+	loops []*loopScope // loops enclosing the node being compiled in the current function, innermost last
+
+	position token.Position // position of the node being compiled
 }
 
 func New() *Compiler {
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
+		sourceMap:           code.SourceMap{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
 	}
@@ -67,8 +92,19 @@ func NewWithState(symbolTable *SymbolTable, constants []object.Object) *Compiler
 }
 
 func (c *Compiler) Compile(node ast.Node) error {
+	if pos := node.Pos(); pos.IsValid() {
+		outer := c.position
+		c.position = pos
+		defer func() { c.position = outer }()
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
+		err := c.hoistFunctionDeclarations(node.Statements)
+		if err != nil {
+			return err
+		}
+
 		for _, s := range node.Statements {
 			err := c.Compile(s)
 			if err != nil {
@@ -78,22 +114,49 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	// Statements
 	case *ast.LetStatement:
+		define := c.symbolTable.Define
+		if node.IsConst() {
+			define = c.symbolTable.DefineConst
+		}
+
+		if node.Pattern != nil {
+			_, err := c.compileDestructuring(node.Pattern, node.Value, func(name *ast.Identifier) error {
+				if s, ok := c.symbolTable.ResolveNoRecursion(name.Value); ok && s.Scope != FunctionScope {
+					return newError(name, "variable %s already declared", name.Value)
+				}
+
+				c.declareSymbol(define(name.Value))
+				return nil
+			})
+
+			return err
+		}
+
 		if s, ok := c.symbolTable.ResolveNoRecursion(node.Name.Value); ok && s.Scope != FunctionScope {
-			return fmt.Errorf("variable %s already declared", node.Name.Value)
+			return newError(node.Name, "variable %s already declared", node.Name.Value)
 		}
 
-		symbol := c.symbolTable.Define(node.Name.Value)
+		symbol := define(node.Name.Value)
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
 		}
 
-		if symbol.Scope == GlobalScope {
-			c.emit(code.OpDeclareGlobal, symbol.Index)
-		} else {
-			c.emit(code.OpDeclareLocal, symbol.Index)
+		c.declareSymbol(symbol)
+
+	case *ast.FunctionDeclaration:
+		// Closures that captured the hoisted function before now share the filled in closure
+		symbol, _ := c.symbolTable.ResolveNoRecursion(node.Name.Value)
+
+		err := c.compileFunctionLiteral(node.Function)
+		if err != nil {
+			return err
 		}
 
+		c.loadSymbol(symbol)
+		c.emit(code.OpFillClosure)
+		c.declareSymbol(symbol)
+
 	case *ast.ReturnStatement:
 		err := c.Compile(node.ReturnValue)
 		if err != nil {
@@ -110,6 +173,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpPop)
 
 	case *ast.BlockStatement:
+		err := c.hoistFunctionDeclarations(node.Statements)
+		if err != nil {
+			return err
+		}
+
 		for _, s := range node.Statements {
 			err := c.Compile(s)
 			if err != nil {
@@ -118,9 +186,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.LoopStatement:
-		c.enterScope()
-
-		currentContinueCount := len(loopContinuePos)
+		loop := c.enterLoopScope(node.Label)
 
 		if node.Initializer != nil {
 			err := c.Compile(node.Initializer)
@@ -152,8 +218,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		// Update any Continue statements with the correct value
-		for _, pos := range loopContinuePos[currentContinueCount:] {
-			c.changeOperand(pos, len(c.currentInstructions()))
+		continuePos := len(c.currentInstructions())
+		for _, pos := range loop.continueJumps {
+			c.changeOperand(pos, continuePos)
 		}
 
 		if node.Update != nil {
@@ -176,45 +243,80 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// Update the `OpJump` with the correct value
 		c.changeOperand(jumpPos, conditionPos)
 
-		c.emit(code.OpBreak)
-
-		freeSymbols := c.symbolTable.FreeSymbols
-		numLoc := c.symbolTable.numDefinitions
-		ins := c.leaveScope()
+		c.leaveLoopScope(continuePos)
 
-		free := make([]object.FreeVariable, len(freeSymbols))
-		for i, s := range freeSymbols {
-			free[i] = object.FreeVariable{Index: s.Index, Scope: s.ScopeIndex}
+	case *ast.ForInStatement:
+		err := c.compileForIn(node.Label, node.Index, node.Element, node.Iterable, func() error {
+			return c.Compile(node.Body)
+		})
+		if err != nil {
+			return err
 		}
 
-		compiled := &object.CompiledLoop{
-			Instructions: ins,
-			NumLocals:    numLoc,
-			Free:         free,
+	case *ast.BreakStatement:
+		depth, err := c.loopDepth(node.Label)
+		if err != nil {
+			return err
 		}
 
-		loopContinuePos = loopContinuePos[:currentContinueCount]
-
-		idx := c.addConstant(compiled)
+		if depth == 0 {
+			c.emit(code.OpBreak)
+		} else {
+			c.emit(code.OpBreakOuter, depth)
+		}
 
-		c.emit(code.OpLoop, idx)
+	case *ast.ContinueStatement:
+		depth, err := c.loopDepth(node.Label)
+		if err != nil {
+			return err
+		}
 
-	case *ast.BreakStatement:
-		c.emit(code.OpBreak)
+		if len(c.loops) == 0 {
+			return newError(node, "continue statement outside loop")
+		}
 
-	case *ast.ContinueStatement:
-		loopContinuePos = append(loopContinuePos, c.emit(code.OpJump, 9999))
+		if depth == 0 {
+			// Emit an `OpJump` with a bogus value, patched once the loop is compiled
+			loop := c.loops[len(c.loops)-1]
+			loop.continueJumps = append(loop.continueJumps, c.emit(code.OpJump, 9999))
+		} else {
+			c.emit(code.OpContinueOuter, depth)
+		}
 
 	// Expressions
 	case *ast.Identifier:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+			return newError(node, "undefined variable %s", node.Value)
 		}
 
 		c.loadSymbol(symbol)
 
 	case *ast.AssignExpression:
+		if node.Pattern != nil {
+			subject, err := c.compileDestructuring(node.Pattern, node.Right, func(name *ast.Identifier) error {
+				symbol, ok := c.symbolTable.Resolve(name.Value)
+				if !ok {
+					return newError(name, "undefined variable %s", name.Value)
+				}
+
+				if symbol.Immutable {
+					return newError(name, "cannot assign to constant %s", name.Value)
+				}
+
+				c.setSymbol(symbol)
+				c.emit(code.OpPop)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			// The assignment evaluates to the whole value
+			c.loadSymbol(subject)
+			break
+		}
+
 		var symbol Symbol
 
 		switch left := node.Left.(type) {
@@ -222,7 +324,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 			var ok bool
 			symbol, ok = c.symbolTable.Resolve(left.Value)
 			if !ok {
-				return fmt.Errorf("undefined variable %s", left.Value)
+				return newError(left, "undefined variable %s", left.Value)
+			}
+
+			if symbol.Immutable {
+				return newError(left, "cannot assign to constant %s", left.Value)
 			}
 
 			if node.Token.Type != token.ASSIGN {
@@ -257,17 +363,23 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpDiv)
 		case "%=":
 			c.emit(code.OpMod)
+		case "**=":
+			c.emit(code.OpPow)
+		case "&=":
+			c.emit(code.OpBitAnd)
+		case "|=":
+			c.emit(code.OpBitOr)
+		case "^=":
+			c.emit(code.OpBitXor)
+		case "<<=":
+			c.emit(code.OpShl)
+		case ">>=":
+			c.emit(code.OpShr)
 		}
 
 		switch node.Left.(type) {
 		case *ast.Identifier:
-			if symbol.Scope == GlobalScope {
-				c.emit(code.OpSetGlobal, symbol.Index)
-			} else if symbol.Scope == FreeScope {
-				c.emit(code.OpSetFree, symbol.Index)
-			} else {
-				c.emit(code.OpSetLocal, symbol.Index)
-			}
+			c.setSymbol(symbol)
 		case *ast.IndexExpression:
 			c.emit(code.OpSetIndex)
 		}
@@ -287,10 +399,23 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpFalse)
 		}
 
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
+
 	case *ast.StringLiteral:
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
 
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			err := c.Compile(part)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpInterpolate, len(node.Parts))
+
 	case *ast.PrefixExpression:
 		err := c.Compile(node.Right)
 		if err != nil {
@@ -302,11 +427,31 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpBang)
 		case "-":
 			c.emit(code.OpMinus)
+		case "~":
+			c.emit(code.OpBitNot)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return newError(node, "unknown operator %s", node.Operator)
 		}
 
 	case *ast.InfixExpression:
+		if node.Operator == "??" {
+			err := c.Compile(node.Left)
+			if err != nil {
+				return err
+			}
+
+			// Emit an `OpJumpNotNull` with a bogus value, the right side only runs if the left one is null
+			jumpNotNullPos := c.emit(code.OpJumpNotNull, 9999)
+
+			err = c.Compile(node.Right)
+			if err != nil {
+				return err
+			}
+
+			c.changeOperand(jumpNotNullPos, len(c.currentInstructions()))
+			break
+		}
+
 		err := c.Compile(node.Left)
 		if err != nil {
 			return err
@@ -328,6 +473,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpDiv)
 		case "%":
 			c.emit(code.OpMod)
+		case "**":
+			c.emit(code.OpPow)
+		case "&":
+			c.emit(code.OpBitAnd)
+		case "|":
+			c.emit(code.OpBitOr)
+		case "^":
+			c.emit(code.OpBitXor)
+		case "<<":
+			c.emit(code.OpShl)
+		case ">>":
+			c.emit(code.OpShr)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
@@ -340,12 +497,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpLessThan)
 		case "<=":
 			c.emit(code.OpLessOrEqual)
+		case "in":
+			c.emit(code.OpIn)
 		case "&&":
 			c.emit(code.OpAnd)
 		case "||":
 			c.emit(code.OpOr)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return newError(node, "unknown operator %s", node.Operator)
 		}
 
 	case *ast.PostfixExpression:
@@ -358,12 +517,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 				op = "-="
 			}
 
-			c.Compile(&ast.AssignExpression{
+			err := c.Compile(&ast.AssignExpression{
 				Left:     node.Left,
 				Operator: op,
 				Token:    node.Token,
 				Right:    &ast.IntegerLiteral{Value: 1},
 			})
+			if err != nil {
+				return err
+			}
 		default:
 			var op string
 			if node.Operator == "++" {
@@ -372,13 +534,31 @@ func (c *Compiler) Compile(node ast.Node) error {
 				op = "-"
 			}
 
-			c.Compile(&ast.InfixExpression{
+			err := c.Compile(&ast.InfixExpression{
 				Left:     node.Left,
 				Operator: op,
 				Right:    &ast.IntegerLiteral{Value: 1},
 			})
+			if err != nil {
+				return err
+			}
+		}
+
+	case *ast.RangeExpression:
+		bounds := []ast.Expression{node.Start, node.End}
+		if node.Step != nil {
+			bounds = append(bounds, node.Step)
 		}
 
+		for _, bound := range bounds {
+			err := c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpRange, len(bounds))
+
 	case *ast.IfExpression:
 		err := c.Compile(node.Condition)
 		if err != nil {
@@ -419,45 +599,42 @@ func (c *Compiler) Compile(node ast.Node) error {
 		afterAlternativePos := len(c.currentInstructions())
 		c.changeOperand(jumpPos, afterAlternativePos)
 
-	case *ast.FunctionLiteral:
-		c.enterScope()
-
-		if node.Name != "" {
-			c.symbolTable.DefineFunctionName(node.Name)
-		}
-
-		for _, p := range node.Parameters {
-			c.symbolTable.Define(p.Value)
-		}
-
-		err := c.Compile(node.Body)
+	case *ast.MatchExpression:
+		err := c.Compile(node.Subject)
 		if err != nil {
 			return err
 		}
 
-		if c.lastInstructionIs(code.OpPop) {
-			c.replaceLastPopWithReturn()
-		}
-		if !c.lastInstructionIs(code.OpReturnValue) {
-			c.emit(code.OpReturn)
-		}
+		// The subject is kept in a hidden variable so each arm can load the parts it tests
+		previous, shadowed := c.symbolTable.ResolveNoRecursion(matchSubjectName)
+		subject := c.symbolTable.Define(matchSubjectName)
+		c.declareSymbol(subject)
 
-		freeSymbols := c.symbolTable.FreeSymbols
-		numLocals := c.symbolTable.numDefinitions
-		instructions := c.leaveScope()
+		jumpPositions := []int{}
+		for _, arm := range node.Arms {
+			jumpPos, err := c.compileMatchArm(arm, subject)
+			if err != nil {
+				return err
+			}
 
-		for _, s := range freeSymbols {
-			c.loadSymbol(s)
+			jumpPositions = append(jumpPositions, jumpPos)
 		}
 
-		compiledFn := &object.CompiledFunction{
-			Instructions:  instructions,
-			NumLocals:     numLocals,
-			NumParameters: len(node.Parameters),
+		// No arm matched
+		c.emit(code.OpNull)
+
+		afterArmsPos := len(c.currentInstructions())
+		for _, pos := range jumpPositions {
+			c.changeOperand(pos, afterArmsPos)
 		}
 
-		fnIndex := c.addConstant(compiledFn)
-		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+		c.symbolTable.Restore(matchSubjectName, previous, shadowed)
+
+	case *ast.FunctionLiteral:
+		err := c.compileFunctionLiteral(node)
+		if err != nil {
+			return err
+		}
 
 	case *ast.CallExpression:
 		err := c.Compile(node.Function)
@@ -465,46 +642,133 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
-		for _, arg := range node.Arguments {
-			err := c.Compile(arg)
+		// with spread arguments, the number of arguments is only known at runtime, so they are collected in an array
+		spread := hasSpread(node.Arguments)
+		if spread {
+			err = c.compileElements(node.Arguments)
 			if err != nil {
 				return err
 			}
+		} else {
+			for _, arg := range node.Arguments {
+				err := c.Compile(arg)
+				if err != nil {
+					return err
+				}
+			}
 		}
 
-		c.emit(code.OpCall, len(node.Arguments))
+		if len(node.Keywords) == 0 {
+			if spread {
+				c.emit(code.OpCallSpread)
+			} else {
+				c.emit(code.OpCall, len(node.Arguments))
+			}
+			break
+		}
 
-	case *ast.ArrayLiteral:
-		for _, el := range node.Elements {
-			err := c.Compile(el)
+		// the names of the keyword arguments are stored as a constant array of strings
+		names := []object.Object{}
+		for _, arg := range node.Keywords {
+			err := c.Compile(arg.Value)
 			if err != nil {
 				return err
 			}
+
+			names = append(names, &object.String{Value: arg.Name.Value})
 		}
 
-		c.emit(code.OpArray, len(node.Elements))
+		namesIndex := c.addConstant(&object.Array{Elements: names})
+		if spread {
+			c.emit(code.OpCallSpreadKeywords, namesIndex)
+		} else {
+			c.emit(code.OpCallKeywords, len(node.Arguments), namesIndex)
+		}
+
+	case *ast.ArrayLiteral:
+		err := c.compileElements(node.Elements)
+		if err != nil {
+			return err
+		}
 
 	case *ast.HashLiteral:
-		keys := []ast.Expression{}
-		for k := range node.Pairs {
-			keys = append(keys, k)
+		// The pairs between two spreads are built into a hash, which is copied to the one built so far like a spread
+		count := 0
+		started := false
+		for _, entry := range node.Entries() {
+			spread, ok := entry.(*ast.SpreadExpression)
+			if !ok {
+				err := c.Compile(entry)
+				if err != nil {
+					return err
+				}
+				err = c.Compile(node.Pairs[entry])
+				if err != nil {
+					return err
+				}
+
+				count++
+				continue
+			}
+
+			c.emitElementsRun(code.OpHash, count*2, code.OpHashSpread, started)
+			started, count = true, 0
+
+			err := c.Compile(spread.Value)
+			if err != nil {
+				return err
+			}
+
+			c.emitAt(spread.Pos(), code.OpHashSpread)
 		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
+
+		c.emitElementsRun(code.OpHash, count*2, code.OpHashSpread, started)
+
+	case *ast.ArrayComprehension:
+		err := c.compileComprehension(node.Clause, code.OpArray, func() error {
+			if spread, ok := node.Element.(*ast.SpreadExpression); ok {
+				err := c.Compile(spread.Value)
+				if err != nil {
+					return err
+				}
+
+				c.emitAt(spread.Pos(), code.OpArraySpread)
+				return nil
+			}
+
+			err := c.Compile(node.Element)
+			if err != nil {
+				return err
+			}
+
+			c.emit(code.OpArray, 1)
+			c.emit(code.OpArraySpread)
+			return nil
 		})
+		if err != nil {
+			return err
+		}
 
-		for _, k := range keys {
-			err := c.Compile(k)
+	case *ast.HashComprehension:
+		err := c.compileComprehension(node.Clause, code.OpHash, func() error {
+			err := c.Compile(node.Key)
 			if err != nil {
 				return err
 			}
-			err = c.Compile(node.Pairs[k])
+
+			err = c.Compile(node.Value)
 			if err != nil {
 				return err
 			}
-		}
 
-		c.emit(code.OpHash, len(node.Pairs)*2)
+			// Errors about the key point at it rather than at the comprehension
+			c.emitAt(node.Key.Pos(), code.OpHash, 2)
+			c.emit(code.OpHashSpread)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
 
 	case *ast.IndexExpression:
 		err := c.Compile(node.Left)
@@ -518,6 +782,27 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		c.emit(code.OpGetIndex)
+
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		// a missing bound is pushed as null
+		for _, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+
+			err = c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpSlice)
 	}
 
 	return nil
@@ -526,6 +811,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
+		SourceMap:    c.currentSourceMap(),
 		Constants:    c.constants,
 	}
 }
@@ -541,9 +827,80 @@ func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 
 	c.setLastInstruction(op, pos)
 
+	if c.position.IsValid() {
+		c.scopes[c.scopeIndex].sourceMap[pos] = c.position
+	}
+
 	return pos
 }
 
+// Emits an instruction at the source position pos instead of the position of the node being compiled
+func (c *Compiler) emitAt(pos token.Position, op code.Opcode, operands ...int) int {
+	outer := c.position
+	c.position = pos
+	defer func() { c.position = outer }()
+
+	return c.emit(op, operands...)
+}
+
+/*
+Compiles the elements of an array literal, or the positional arguments of a call with spreads, into an array
+
+Without spreads this is a plain OpArray. Otherwise the elements before the first spread make the
+array, each spread is appended to it with OpArraySpread, and so is each run of elements between spreads
+*/
+func (c *Compiler) compileElements(elements []ast.Expression) error {
+	count := 0
+	started := false
+	for _, el := range elements {
+		spread, ok := el.(*ast.SpreadExpression)
+		if !ok {
+			err := c.Compile(el)
+			if err != nil {
+				return err
+			}
+
+			count++
+			continue
+		}
+
+		c.emitElementsRun(code.OpArray, count, code.OpArraySpread, started)
+		started, count = true, 0
+
+		err := c.Compile(spread.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emitAt(spread.Pos(), code.OpArraySpread)
+	}
+
+	c.emitElementsRun(code.OpArray, count, code.OpArraySpread, started)
+	return nil
+}
+
+// Builds the n elements compiled since the last spread with build, merging them into the value built so far once started
+func (c *Compiler) emitElementsRun(build code.Opcode, n int, merge code.Opcode, started bool) {
+	switch {
+	case !started:
+		c.emit(build, n)
+	case n > 0:
+		c.emit(build, n)
+		c.emit(merge)
+	}
+}
+
+// Reports whether a list of elements or arguments contains a spread
+func hasSpread(elements []ast.Expression) bool {
+	for _, el := range elements {
+		if _, ok := el.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *Compiler) addInstruction(ins code.Instructions) int {
 	posNewInstruction := len(c.currentInstructions())
 	updatedInstructions := append(c.currentInstructions(), ins...)
@@ -576,6 +933,8 @@ func (c *Compiler) removeLastPop() {
 	old := c.currentInstructions()
 	new := old[:last.Position]
 
+	delete(c.scopes[c.scopeIndex].sourceMap, last.Position)
+
 	c.scopes[c.scopeIndex].instructions = new
 	c.scopes[c.scopeIndex].lastInstruction = previous
 }
@@ -599,9 +958,14 @@ func (c *Compiler) currentInstructions() code.Instructions {
 	return c.scopes[c.scopeIndex].instructions
 }
 
+func (c *Compiler) currentSourceMap() code.SourceMap {
+	return c.scopes[c.scopeIndex].sourceMap
+}
+
 func (c *Compiler) enterScope() {
 	scope := CompilationScope{
 		instructions:        code.Instructions{},
+		sourceMap:           code.SourceMap{},
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
 	}
@@ -623,6 +987,187 @@ func (c *Compiler) leaveScope() code.Instructions {
 	return instructions
 }
 
+func (c *Compiler) enterLoopScope(label *ast.Identifier) *loopScope {
+	c.enterScope()
+	c.symbolTable.loop = true
+
+	loop := &loopScope{}
+	if label != nil {
+		loop.label = label.Value
+	}
+
+	c.loops = append(c.loops, loop)
+	return loop
+}
+
+// Leaves the scope of a loop body and emits the loop, whose next iteration starts at continuePos
+func (c *Compiler) leaveLoopScope(continuePos int) {
+	c.emit(code.OpBreak)
+	c.loops = c.loops[:len(c.loops)-1]
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLoc := c.symbolTable.numDefinitions
+	sourceMap := c.currentSourceMap()
+	ins := c.leaveScope()
+
+	free := make([]object.FreeVariable, len(freeSymbols))
+	for i, s := range freeSymbols {
+		free[i] = loopVariable(c.symbolTable, s)
+	}
+
+	compiled := &object.CompiledLoop{
+		Instructions: ins,
+		SourceMap:    sourceMap,
+		NumLocals:    numLoc,
+		Free:         free,
+		ContinuePos:  continuePos,
+	}
+
+	idx := c.addConstant(compiled)
+
+	c.emit(code.OpLoop, idx)
+}
+
+// Compiles a for-in loop over iterable, binding index (or nil) and element on every iteration before the body
+func (c *Compiler) compileForIn(label, index, element *ast.Identifier, iterable ast.Expression, compileBody func() error) error {
+	loop := c.enterLoopScope(label)
+
+	err := c.Compile(iterable)
+	if err != nil {
+		return err
+	}
+
+	// Errors about the iterable point at it rather than at the loop
+	c.emitAt(iterable.Pos(), code.OpIter)
+
+	iterator := c.symbolTable.Define(iteratorName)
+	c.declareSymbol(iterator)
+
+	elementSymbol := c.symbolTable.Define(element.Value)
+	numVariables := 1
+
+	var indexSymbol Symbol
+	if index != nil {
+		indexSymbol = c.symbolTable.Define(index.Value)
+		numVariables = 2
+	}
+
+	nextPos := len(c.currentInstructions())
+
+	c.loadSymbol(iterator)
+	c.emit(code.OpIterNext, numVariables)
+
+	// Emit an `OpJumpNotTruthy` with a bogus value
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.declareSymbol(elementSymbol)
+	if index != nil {
+		c.declareSymbol(indexSymbol)
+	}
+
+	err = compileBody()
+	if err != nil {
+		return err
+	}
+
+	// Update any Continue statements with the correct value
+	for _, pos := range loop.continueJumps {
+		c.changeOperand(pos, nextPos)
+	}
+
+	c.emit(code.OpJump, nextPos)
+
+	afterBodyPos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterBodyPos)
+
+	c.leaveLoopScope(nextPos)
+	return nil
+}
+
+/*
+Compiles a comprehension to a for-in loop that adds entries to an accumulator, then pushes the accumulator
+
+The accumulator is an empty array or hash made by build, kept in a hidden variable of the enclosing scope.
+On every iteration whose condition holds, it's loaded and addEntry compiles the code that extends it in place
+*/
+func (c *Compiler) compileComprehension(clause *ast.ComprehensionClause, build code.Opcode, addEntry func() error) error {
+	c.emit(build, 0)
+
+	previous, shadowed := c.symbolTable.ResolveNoRecursion(accumulatorName)
+	accumulator := c.symbolTable.Define(accumulatorName)
+	c.declareSymbol(accumulator)
+
+	err := c.compileForIn(nil, clause.Index, clause.Element, clause.Iterable, func() error {
+		jumpNotTruthyPos := -1
+		if clause.Condition != nil {
+			err := c.Compile(clause.Condition)
+			if err != nil {
+				return err
+			}
+
+			// Emit an `OpJumpNotTruthy` with a bogus value, skipping the entry
+			jumpNotTruthyPos = c.emit(code.OpJumpNotTruthy, 9999)
+		}
+
+		symbol, _ := c.symbolTable.Resolve(accumulatorName)
+		c.loadSymbol(symbol)
+
+		err := addEntry()
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpPop)
+
+		if jumpNotTruthyPos >= 0 {
+			c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.loadSymbol(accumulator)
+	c.symbolTable.Restore(accumulatorName, previous, shadowed)
+
+	return nil
+}
+
+// Returns how many loops the loop named by label encloses around the current one, 0 when label is nil
+// A label names the innermost enclosing loop with that label
+func (c *Compiler) loopDepth(label *ast.Identifier) (int, error) {
+	if label == nil {
+		return 0, nil
+	}
+
+	for i := len(c.loops) - 1; i >= 0; i-- {
+		if c.loops[i].label == label.Value {
+			return len(c.loops) - 1 - i, nil
+		}
+	}
+
+	return 0, newError(label, "unknown label %s", label.Value)
+}
+
+// Locates a variable of the enclosing scopes used in a loop body, relative to the frame of the loop
+// A variable of an enclosing loop is located where that loop finds it, one frame further down
+func loopVariable(outer *SymbolTable, s Symbol) object.FreeVariable {
+	switch {
+	case s.Scope == FreeScope && outer.loop:
+		variable := loopVariable(outer.Outer, outer.FreeSymbols[s.Index])
+		variable.Scope++
+		return variable
+	case s.Scope == FreeScope:
+		return object.FreeVariable{Index: s.Index, Scope: 1, Kind: object.ClosureFreeVariable}
+	case s.Scope == FunctionScope:
+		return object.FreeVariable{Scope: 1, Kind: object.CurrentClosureVariable}
+	default:
+		return object.FreeVariable{Index: s.Index, Scope: 1, Kind: object.LocalVariable}
+	}
+}
+
 func (c *Compiler) replaceLastPopWithReturn() {
 	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
 	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
@@ -644,3 +1189,347 @@ func (c *Compiler) loadSymbol(s Symbol) {
 		c.emit(code.OpCurrentClosure)
 	}
 }
+
+func (c *Compiler) setSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else if s.Scope == FreeScope {
+		c.emit(code.OpSetFree, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) declareSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpDeclareGlobal, s.Index)
+	} else {
+		c.emit(code.OpDeclareLocal, s.Index)
+	}
+}
+
+// Compiles a function literal to a closure
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	// Break and continue statements in the body can't target the loops around the function
+	outerLoops := c.loops
+	c.loops = nil
+	defer func() { c.loops = outerLoops }()
+
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+
+	// Parameters take the first local slots, in order, but each one is hidden until the defaults before it
+	// are compiled, so like in the evaluator a default can only refer to the parameters before it
+	names := append([]*ast.Identifier{}, node.Parameters...)
+	if node.Rest != nil {
+		names = append(names, node.Rest)
+	}
+
+	params := make([]Symbol, len(names))
+	for i, name := range names {
+		previous, shadowed := c.symbolTable.ResolveNoRecursion(name.Value)
+		params[i] = c.symbolTable.Define(name.Value)
+		c.symbolTable.Restore(name.Value, previous, shadowed)
+	}
+
+	// Default values are evaluated when the call doesn't pass their argument
+	numDefaults := 0
+	for i := range node.Parameters {
+		if i < len(node.Defaults) && node.Defaults[i] != nil {
+			numDefaults++
+
+			// Emit an `OpJumpArgPassed` with a bogus value
+			jumpPos := c.emit(code.OpJumpArgPassed, 9999, i)
+
+			err := c.Compile(node.Defaults[i])
+			if err != nil {
+				return err
+			}
+
+			c.emit(code.OpDeclareLocal, params[i].Index)
+
+			afterDefaultPos := len(c.currentInstructions())
+			c.replaceInstruction(jumpPos, code.Make(code.OpJumpArgPassed, afterDefaultPos, i))
+		}
+
+		c.symbolTable.Restore(names[i].Value, params[i], true)
+	}
+
+	if node.Rest != nil {
+		c.symbolTable.Restore(node.Rest.Value, params[len(params)-1], true)
+	}
+
+	err := c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	sourceMap := c.currentSourceMap()
+	instructions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		c.loadSymbol(s)
+	}
+
+	parameters := []string{}
+	for _, p := range node.Parameters {
+		parameters = append(parameters, p.Value)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		SourceMap:     sourceMap,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumDefaults:   numDefaults,
+		Variadic:      node.Rest != nil,
+		Parameters:    parameters,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+// Defines the names of the functions declared in a list of statements, so they can be used before their declaration
+// Each name holds an empty closure, filled in when its declaration runs
+func (c *Compiler) hoistFunctionDeclarations(statements []ast.Statement) error {
+	for _, stmt := range statements {
+		decl, ok := stmt.(*ast.FunctionDeclaration)
+		if !ok {
+			continue
+		}
+
+		if s, ok := c.symbolTable.ResolveNoRecursion(decl.Name.Value); ok && s.Scope != FunctionScope {
+			return newError(decl.Name, "variable %s already declared", decl.Name.Value)
+		}
+
+		c.emit(code.OpHoistClosure)
+		c.declareSymbol(c.symbolTable.Define(decl.Name.Value))
+	}
+
+	return nil
+}
+
+// Compiles a match arm and returns the position of its jump to the end of the match expression
+func (c *Compiler) compileMatchArm(arm *ast.MatchArm, subject Symbol) (int, error) {
+	failJumps := []int{}
+	bindings := []shadowedSymbol{}
+
+	bind := func(name *ast.Identifier) error {
+		previous, ok := c.symbolTable.ResolveNoRecursion(name.Value)
+		bindings = append(bindings, shadowedSymbol{name: name.Value, symbol: previous, ok: ok})
+
+		c.declareSymbol(c.symbolTable.Define(name.Value))
+		return nil
+	}
+
+	err := c.compilePattern(arm.Pattern, subject, []ast.Expression{}, &failJumps, bind)
+	if err != nil {
+		return 0, err
+	}
+
+	if arm.Guard != nil {
+		err := c.Compile(arm.Guard)
+		if err != nil {
+			return 0, err
+		}
+
+		failJumps = append(failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+	}
+
+	err = c.Compile(arm.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	// Emit an `OpJump` with a bogus value
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	nextArmPos := len(c.currentInstructions())
+	for _, pos := range failJumps {
+		c.changeOperand(pos, nextArmPos)
+	}
+
+	// The names bound by the pattern are only visible in the arm
+	for i := len(bindings) - 1; i >= 0; i-- {
+		c.symbolTable.Restore(bindings[i].name, bindings[i].symbol, bindings[i].ok)
+	}
+
+	return jumpPos, nil
+}
+
+// Compiles a destructuring let or assignment, calling bind to store each value the pattern binds
+func (c *Compiler) compileDestructuring(pattern ast.Pattern, value ast.Expression, bind func(name *ast.Identifier) error) (Symbol, error) {
+	err := c.Compile(value)
+	if err != nil {
+		return Symbol{}, err
+	}
+
+	// The value is kept in a hidden variable so each binding can load its part
+	previous, shadowed := c.symbolTable.ResolveNoRecursion(destructuredValueName)
+	subject := c.symbolTable.Define(destructuredValueName)
+	c.declareSymbol(subject)
+
+	err = c.compilePattern(pattern, subject, []ast.Expression{}, nil, bind)
+	if err != nil {
+		return Symbol{}, err
+	}
+
+	c.symbolTable.Restore(destructuredValueName, previous, shadowed)
+
+	return subject, nil
+}
+
+/*
+Compiles a pattern matched against the part of the subject at path
+
+With the part's value on top of the stack, bind is called to store it for each name the pattern binds.
+Each failed test jumps past the pattern, the positions of those jumps are appended to failJumps.
+A nil failJumps skips the tests, as when destructuring, where a missing element or key is null
+*/
+func (c *Compiler) compilePattern(pattern ast.Pattern, subject Symbol, path []ast.Expression, failJumps *[]int, bind func(name *ast.Identifier) error) error {
+	test := failJumps != nil
+
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		// matches anything
+
+	case *ast.BindingPattern:
+		err := c.loadPatternValue(subject, path)
+		if err != nil {
+			return err
+		}
+
+		return bind(pattern.Name)
+
+	case *ast.LiteralPattern:
+		if !test {
+			return newError(pattern, "literal pattern %s can't be used to destructure a value", pattern)
+		}
+
+		err := c.loadPatternValue(subject, path)
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(pattern.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpEqual)
+		*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+
+	case *ast.ArrayPattern:
+		if test {
+			err := c.loadPatternValue(subject, path)
+			if err != nil {
+				return err
+			}
+
+			hasRest := 0
+			if pattern.Rest != nil {
+				hasRest = 1
+			}
+
+			c.emit(code.OpMatchArray, len(pattern.Elements), hasRest)
+			*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		for i, element := range pattern.Elements {
+			index := &ast.IntegerLiteral{Value: int64(i)}
+			err := c.compilePattern(element, subject, extendPath(path, index), failJumps, bind)
+			if err != nil {
+				return err
+			}
+		}
+
+		if rest, ok := pattern.Rest.(*ast.BindingPattern); ok {
+			err := c.loadPatternValue(subject, path)
+			if err != nil {
+				return err
+			}
+
+			c.emit(code.OpArrayRest, len(pattern.Elements))
+
+			return bind(rest.Name)
+		}
+
+	case *ast.HashPattern:
+		if test {
+			err := c.loadPatternValue(subject, path)
+			if err != nil {
+				return err
+			}
+
+			for _, key := range pattern.Keys {
+				err := c.Compile(key)
+				if err != nil {
+					return err
+				}
+			}
+
+			c.emit(code.OpMatchHash, len(pattern.Keys))
+			*failJumps = append(*failJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		for i, value := range pattern.Values {
+			err := c.compilePattern(value, subject, extendPath(path, pattern.Keys[i]), failJumps, bind)
+			if err != nil {
+				return err
+			}
+		}
+
+	default:
+		return newError(pattern, "unknown pattern %s", pattern)
+	}
+
+	return nil
+}
+
+// Loads the part of the subject found by indexing it with each element of path in turn
+func (c *Compiler) loadPatternValue(subject Symbol, path []ast.Expression) error {
+	c.loadSymbol(subject)
+
+	for _, index := range path {
+		err := c.Compile(index)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpGetIndex)
+	}
+
+	return nil
+}
+
+func extendPath(path []ast.Expression, index ast.Expression) []ast.Expression {
+	extended := make([]ast.Expression, len(path), len(path)+1)
+	copy(extended, path)
+
+	return append(extended, index)
+}
+
+func newError(node ast.Node, format string, a ...interface{}) error {
+	return fmt.Errorf("%s: %s", node.Pos(), fmt.Sprintf(format, a...))
+}
@@ -76,6 +76,18 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "2 ** 3 ** 2",
+			expectedConstants: []interface{}{2, 3, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpPow),
+				code.Make(code.OpPow),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "1; 2",
 			expectedConstants: []interface{}{1, 2},
@@ -130,6 +142,64 @@ func TestIntegerArithmetic(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestBitwiseOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 & 2; 1 | 2; 1 ^ 2; 1 << 2; 1 >> 2",
+			expectedConstants: []interface{}{1, 2, 1, 2, 1, 2, 1, 2, 1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitAnd),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpBitOr),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpBitXor),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 6),
+				code.Make(code.OpConstant, 7),
+				code.Make(code.OpShl),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 8),
+				code.Make(code.OpConstant, 9),
+				code.Make(code.OpShr),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "~1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpBitNot),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			let a = 1;
+			a <<= 2;
+			`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDeclareGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpShl),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestBooleanExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -217,6 +287,17 @@ func TestBooleanExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "1 in [1]",
+			expectedConstants: []interface{}{1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpIn),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "1 <= 1",
 			expectedConstants: []interface{}{1, 1},
@@ -366,6 +447,165 @@ func TestGlobalLetStatements(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestNull(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "null",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "null ?? 1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpNull),
+				// 0001
+				code.Make(code.OpJumpNotNull, 7),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "match (1) { 1 => 2, [x] if x => x }",
+			expectedConstants: []interface{}{1, 1, 2, 0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpDeclareGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpEqual),
+				// 0013
+				code.Make(code.OpJumpNotTruthy, 22),
+				// 0016
+				code.Make(code.OpConstant, 2),
+				// 0019
+				code.Make(code.OpJump, 55),
+				// 0022
+				code.Make(code.OpGetGlobal, 0),
+				// 0025
+				code.Make(code.OpMatchArray, 1, 0),
+				// 0029
+				code.Make(code.OpJumpNotTruthy, 54),
+				// 0032
+				code.Make(code.OpGetGlobal, 0),
+				// 0035
+				code.Make(code.OpConstant, 3),
+				// 0038
+				code.Make(code.OpGetIndex),
+				// 0039
+				code.Make(code.OpDeclareGlobal, 1),
+				// 0042
+				code.Make(code.OpGetGlobal, 1),
+				// 0045
+				code.Make(code.OpJumpNotTruthy, 54),
+				// 0048
+				code.Make(code.OpGetGlobal, 1),
+				// 0051
+				code.Make(code.OpJump, 55),
+				// 0054
+				code.Make(code.OpNull),
+				// 0055
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `match ({}) { {"a": _} => 1 }`,
+			expectedConstants: []interface{}{"a", 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpHash, 0),
+				// 0003
+				code.Make(code.OpDeclareGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpConstant, 0),
+				// 0012
+				code.Make(code.OpMatchHash, 1),
+				// 0015
+				code.Make(code.OpJumpNotTruthy, 24),
+				// 0018
+				code.Make(code.OpConstant, 1),
+				// 0021
+				code.Make(code.OpJump, 25),
+				// 0024
+				code.Make(code.OpNull),
+				// 0025
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let [a, ...b] = [1];",
+			expectedConstants: []interface{}{1, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpDeclareGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGetIndex),
+				code.Make(code.OpDeclareGlobal, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpArrayRest, 1),
+				code.Make(code.OpDeclareGlobal, 2),
+			},
+		},
+		{
+			input:             "let a = 1; let b = 2; [a, b] = [b, a];",
+			expectedConstants: []interface{}{1, 2, 0, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDeclareGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpDeclareGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpDeclareGlobal, 2),
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpGetIndex),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpGetIndex),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestStringExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -386,6 +626,46 @@ func TestStringExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             `"a ${1} b"`,
+			expectedConstants: []interface{}{"a ", 1, " b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpInterpolate, 3),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestRangeExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `0..10`,
+			expectedConstants: []interface{}{0, 10},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpRange, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `10..0:-2`,
+			expectedConstants: []interface{}{10, 0, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpMinus),
+				code.Make(code.OpRange, 3),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -497,16 +777,268 @@ func TestIndexExpressions(t *testing.T) {
 			},
 		},
 		{
-			input:             "{1: 2}[2 - 1]",
-			expectedConstants: []interface{}{1, 2, 2, 1},
+			input:             "{1: 2}[2 - 1]",
+			expectedConstants: []interface{}{1, 2, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSub),
+				code.Make(code.OpGetIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1, 2][1:]",
+			expectedConstants: []interface{}{1, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"ab"[:-1]`,
+			expectedConstants: []interface{}{"ab", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMinus),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `fn() { return 5 + 10 }`,
+			expectedConstants: []interface{}{
+				5,
+				10,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn() { 5 + 10 }`,
+			expectedConstants: []interface{}{
+				5,
+				10,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn() { 1; 2 }`,
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn() { }`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `one(); fn one() { 1 }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHoistClosure),
+				code.Make(code.OpDeclareGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpCall, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpFillClosure),
+				code.Make(code.OpDeclareGlobal, 0),
+			},
+		},
+		{
+			input: `fn() { fn a() { b() } fn b() { a() } }`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpHoistClosure),
+					code.Make(code.OpDeclareLocal, 0),
+					code.Make(code.OpHoistClosure),
+					code.Make(code.OpDeclareLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpFillClosure),
+					code.Make(code.OpDeclareLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpFillClosure),
+					code.Make(code.OpDeclareLocal, 1),
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestKeywordArguments(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			let f = fn(a, b) { };
+			f(1, b: 2);
+			`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+				1,
+				2,
+				[]string{"b"},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpDeclareGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCallKeywords, 1, 3),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[1, ...[2], 3]",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpArraySpread),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpArraySpread),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{...{}, 1: 2}",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpHashSpread),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpHashSpread),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "len(...[1])",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpArraySpread),
+				code.Make(code.OpCallSpread),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "push(...[[]], element: 1)",
+			expectedConstants: []interface{}{1, []string{"element"}},
 			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 5),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpArraySpread),
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpHash, 2),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpSub),
-				code.Make(code.OpGetIndex),
+				code.Make(code.OpCallSpreadKeywords, 1),
 				code.Make(code.OpPop),
 			},
 		},
@@ -515,68 +1047,122 @@ func TestIndexExpressions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
-func TestFunctions(t *testing.T) {
+func TestComprehensions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
-			input: `fn() { return 5 + 10 }`,
+			input: `[x for x in [] if x]`,
 			expectedConstants: []interface{}{
-				5,
-				10,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
-					code.Make(code.OpConstant, 1),
-					code.Make(code.OpAdd),
-					code.Make(code.OpReturnValue),
+					// 0000 - iterable ([])
+					code.Make(code.OpArray, 0),
+					// 0003
+					code.Make(code.OpIter),
+					// 0004
+					code.Make(code.OpDeclareLocal, 0),
+					// 0006 - next element
+					code.Make(code.OpGetLocal, 0),
+					// 0008
+					code.Make(code.OpIterNext, 1),
+					// 0010 - exit loop once exhausted
+					code.Make(code.OpJumpNotTruthy, 33),
+					// 0013
+					code.Make(code.OpDeclareLocal, 1),
+					// 0015 - condition (x), skipping the element when falsy
+					code.Make(code.OpGetLocal, 1),
+					// 0017
+					code.Make(code.OpJumpNotTruthy, 30),
+					// 0020 - append [x] to the accumulator
+					code.Make(code.OpGetGlobal, 0),
+					// 0023
+					code.Make(code.OpGetLocal, 1),
+					// 0025
+					code.Make(code.OpArray, 1),
+					// 0028
+					code.Make(code.OpArraySpread),
+					// 0029
+					code.Make(code.OpPop),
+					// 0030 - jump back to the next element
+					code.Make(code.OpJump, 6),
+					// 0033 - exit loop
+					code.Make(code.OpBreak),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpDeclareGlobal, 0),
+				code.Make(code.OpLoop, 0),
+				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpPop),
 			},
 		},
 		{
-			input: `fn() { 5 + 10 }`,
+			input: `{k: v for k, v in {}}`,
 			expectedConstants: []interface{}{
-				5,
-				10,
 				[]code.Instructions{
-					code.Make(code.OpConstant, 0),
-					code.Make(code.OpConstant, 1),
-					code.Make(code.OpAdd),
-					code.Make(code.OpReturnValue),
+					// 0000 - iterable ({})
+					code.Make(code.OpHash, 0),
+					// 0003
+					code.Make(code.OpIter),
+					// 0004
+					code.Make(code.OpDeclareLocal, 0),
+					// 0006 - next key and value
+					code.Make(code.OpGetLocal, 0),
+					// 0008
+					code.Make(code.OpIterNext, 2),
+					// 0010 - exit loop once exhausted
+					code.Make(code.OpJumpNotTruthy, 32),
+					// 0013
+					code.Make(code.OpDeclareLocal, 1),
+					// 0015
+					code.Make(code.OpDeclareLocal, 2),
+					// 0017 - merge {k: v} into the accumulator
+					code.Make(code.OpGetGlobal, 0),
+					// 0020
+					code.Make(code.OpGetLocal, 2),
+					// 0022
+					code.Make(code.OpGetLocal, 1),
+					// 0024
+					code.Make(code.OpHash, 2),
+					// 0027
+					code.Make(code.OpHashSpread),
+					// 0028
+					code.Make(code.OpPop),
+					// 0029 - jump back to the next key and value
+					code.Make(code.OpJump, 6),
+					// 0032 - exit loop
+					code.Make(code.OpBreak),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpDeclareGlobal, 0),
+				code.Make(code.OpLoop, 0),
+				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpPop),
 			},
 		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctionDefaultParameters(t *testing.T) {
+	tests := []compilerTestCase{
 		{
-			input: `fn() { 1; 2 }`,
+			input: `fn(a, b = 2) { b }`,
 			expectedConstants: []interface{}{
-				1,
 				2,
 				[]code.Instructions{
+					code.Make(code.OpJumpArgPassed, 9, 1),
 					code.Make(code.OpConstant, 0),
-					code.Make(code.OpPop),
-					code.Make(code.OpConstant, 1),
+					code.Make(code.OpDeclareLocal, 1),
+					// 0009
+					code.Make(code.OpGetLocal, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 2, 0),
-				code.Make(code.OpPop),
-			},
-		},
-		{
-			input: `fn() { }`,
-			expectedConstants: []interface{}{
-				[]code.Instructions{
-					code.Make(code.OpReturn),
-				},
-			},
-			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpClosure, 1, 0),
 				code.Make(code.OpPop),
 			},
 		},
@@ -1470,6 +2056,80 @@ func TestForLoops(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestForInLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `for (x in [1]) { x }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					// 0000 - iterable ([1])
+					code.Make(code.OpConstant, 0),
+					// 0003
+					code.Make(code.OpArray, 1),
+					// 0006
+					code.Make(code.OpIter),
+					// 0007
+					code.Make(code.OpDeclareLocal, 0),
+					// 0009 - next element
+					code.Make(code.OpGetLocal, 0),
+					// 0011
+					code.Make(code.OpIterNext, 1),
+					// 0013 - exit loop once exhausted
+					code.Make(code.OpJumpNotTruthy, 24),
+					// 0016
+					code.Make(code.OpDeclareLocal, 1),
+					// 0018 - loop body ({ x })
+					code.Make(code.OpGetLocal, 1),
+					// 0020
+					code.Make(code.OpPop),
+					// 0021 - jump back to the next element
+					code.Make(code.OpJump, 9),
+					// 0024 - exit loop
+					code.Make(code.OpBreak),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpLoop, 1),
+			},
+		},
+		{
+			input: `for (k, v in {}) { continue }`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					// 0000 - iterable ({})
+					code.Make(code.OpHash, 0),
+					// 0003
+					code.Make(code.OpIter),
+					// 0004
+					code.Make(code.OpDeclareLocal, 0),
+					// 0006 - next key and value
+					code.Make(code.OpGetLocal, 0),
+					// 0008
+					code.Make(code.OpIterNext, 2),
+					// 0010 - exit loop once exhausted
+					code.Make(code.OpJumpNotTruthy, 23),
+					// 0013
+					code.Make(code.OpDeclareLocal, 1),
+					// 0015
+					code.Make(code.OpDeclareLocal, 2),
+					// 0017 - continue
+					code.Make(code.OpJump, 6),
+					// 0020 - jump back to the next key and value
+					code.Make(code.OpJump, 6),
+					// 0023 - exit loop
+					code.Make(code.OpBreak),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpLoop, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestCompoundAssignment(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -1552,6 +2212,22 @@ func TestCompoundAssignment(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input: `
+			let a = 1;
+			a **= 2;
+			`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDeclareGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPow),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input: `
 			let a = 1;
@@ -1737,6 +2413,121 @@ func TestContinueStatement(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestLabeledLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			outer: while (true) {
+				while (true) {
+					break outer;
+				}
+			}
+			`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					// 0000 - init
+					code.Make(code.OpNull),
+					// 0001
+					code.Make(code.OpPop),
+					// 0002 - condition
+					code.Make(code.OpTrue),
+					// 0003
+					code.Make(code.OpJumpNotTruthy, 13),
+					// 0006 - loop body
+					code.Make(code.OpBreakOuter, 1),
+					// 0008 - update
+					code.Make(code.OpNull),
+					// 0009
+					code.Make(code.OpPop),
+					// 0010 - jump back to condition
+					code.Make(code.OpJump, 2),
+					// 0013 - exit loop
+					code.Make(code.OpBreak),
+				},
+				[]code.Instructions{
+					// 0000 - init
+					code.Make(code.OpNull),
+					// 0001
+					code.Make(code.OpPop),
+					// 0002 - condition
+					code.Make(code.OpTrue),
+					// 0003
+					code.Make(code.OpJumpNotTruthy, 14),
+					// 0006 - loop body
+					code.Make(code.OpLoop, 0),
+					// 0009 - update
+					code.Make(code.OpNull),
+					// 0010
+					code.Make(code.OpPop),
+					// 0011 - jump back to condition
+					code.Make(code.OpJump, 2),
+					// 0014 - exit loop
+					code.Make(code.OpBreak),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpLoop, 1),
+			},
+		},
+		{
+			input: `
+			outer: while (true) {
+				inner: while (true) {
+					continue outer;
+				}
+			}
+			`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					// 0000 - init
+					code.Make(code.OpNull),
+					// 0001
+					code.Make(code.OpPop),
+					// 0002 - condition
+					code.Make(code.OpTrue),
+					// 0003
+					code.Make(code.OpJumpNotTruthy, 13),
+					// 0006 - loop body
+					code.Make(code.OpContinueOuter, 1),
+					// 0008 - update
+					code.Make(code.OpNull),
+					// 0009
+					code.Make(code.OpPop),
+					// 0010 - jump back to condition
+					code.Make(code.OpJump, 2),
+					// 0013 - exit loop
+					code.Make(code.OpBreak),
+				},
+				[]code.Instructions{
+					// 0000 - init
+					code.Make(code.OpNull),
+					// 0001
+					code.Make(code.OpPop),
+					// 0002 - condition
+					code.Make(code.OpTrue),
+					// 0003
+					code.Make(code.OpJumpNotTruthy, 14),
+					// 0006 - loop body
+					code.Make(code.OpLoop, 0),
+					// 0009 - update
+					code.Make(code.OpNull),
+					// 0010
+					code.Make(code.OpPop),
+					// 0011 - jump back to condition
+					code.Make(code.OpJump, 2),
+					// 0014 - exit loop
+					code.Make(code.OpBreak),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpLoop, 1),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestPostfixOperators(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -1787,3 +2578,42 @@ func TestPostfixOperators(t *testing.T) {
 
 	runCompilerTests(t, tests)
 }
+
+func TestCompilerErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1;\nb;", "2:1: undefined variable b"},
+		{"let a = 1;\nlet f = fn() {\n  c = 2;\n};", "3:3: undefined variable c"},
+		{"let f = fn(a = b, b = 1) { a + 1 };", "1:16: undefined variable b"},
+		{"let f = fn(a = a) { a };", "1:16: undefined variable a"},
+		{"let a = 1;\nlet a = 2;", "2:5: variable a already declared"},
+		{"fn f() { 1 }\nfn f() { 2 }", "2:4: variable f already declared"},
+		{"fn f() { 1 }\nlet f = 2;", "2:5: variable f already declared"},
+		{"const a = 1;\na = 2;", "2:1: cannot assign to constant a"},
+		{"const a = 1;\na += 2;", "2:1: cannot assign to constant a"},
+		{"const a = 1;\na++;", "2:1: cannot assign to constant a"},
+		{"const a = 1;\nlet f = fn() { a = 2 };", "2:16: cannot assign to constant a"},
+		{"const a = 1;\nfor (;;) { a = 2 }", "2:12: cannot assign to constant a"},
+		{"let b = 1;\nconst [a] = [1];\n[b, a] = [2, 3];", "3:5: cannot assign to constant a"},
+		{"const a = 1;\nconst a = 2;", "2:7: variable a already declared"},
+		{"for (;;) {\n  break outer;\n}", "2:9: unknown label outer"},
+		{"outer: for (;;) {\n  let f = fn() { continue outer };\n}", "2:27: unknown label outer"},
+		{"let a = 1;\ncontinue;", "2:1: continue statement outside loop"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("expected compiler error for %q, got none", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong compiler error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
@@ -117,6 +117,22 @@ func testConstants(t *testing.T, expected []interface{}, actual []object.Object)
 			if err != nil {
 				return fmt.Errorf("constant %d - testFloatObject failed: %s", i, err)
 			}
+		case []string:
+			array, ok := actual[i].(*object.Array)
+			if !ok {
+				return fmt.Errorf("constant %d - not an array: %T", i, actual[i])
+			}
+
+			if len(array.Elements) != len(constant) {
+				return fmt.Errorf("constant %d - wrong number of elements. got=%d, want=%d", i, len(array.Elements), len(constant))
+			}
+
+			for j, expectedElement := range constant {
+				err := testStringObject(expectedElement, array.Elements[j])
+				if err != nil {
+					return fmt.Errorf("constant %d - element %d - testStringObject failed: %s", i, j, err)
+				}
+			}
 		}
 	}
 
@@ -15,12 +15,15 @@ type Symbol struct {
 	Scope      SymbolScope
 	Index      int
 	ScopeIndex int
+	Immutable  bool // true for constants, which can't be assigned to
 }
 
 type SymbolTable struct {
 	Outer      *SymbolTable
 	ScopeIndex int
 
+	loop bool // true for the scope of a loop body, whose free variables live in the frames below it
+
 	store          map[string]Symbol
 	numDefinitions int
 
@@ -54,6 +57,15 @@ func (s *SymbolTable) Define(name string) Symbol {
 	return symbol
 }
 
+// Defines a constant, a symbol that can't be assigned to after its declaration
+func (s *SymbolTable) DefineConst(name string) Symbol {
+	symbol := s.Define(name)
+	symbol.Immutable = true
+
+	s.store[name] = symbol
+	return symbol
+}
+
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := s.store[name]
 
@@ -79,6 +91,15 @@ func (s *SymbolTable) ResolveNoRecursion(name string) (Symbol, bool) {
 	return obj, ok
 }
 
+// Restores what a name resolved to in this table before Define shadowed it // ok is false if it was undefined
+func (s *SymbolTable) Restore(name string, previous Symbol, ok bool) {
+	if ok {
+		s.store[name] = previous
+	} else {
+		delete(s.store, name)
+	}
+}
+
 func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope, ScopeIndex: -1}
 	s.store[name] = symbol
@@ -94,7 +115,7 @@ func (s *SymbolTable) DefineFunctionName(name string) Symbol {
 func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 
-	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Immutable: original.Immutable}
 	symbol.Scope = FreeScope
 	symbol.ScopeIndex = original.ScopeIndex
 
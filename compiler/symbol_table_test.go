@@ -325,3 +325,60 @@ func TestShadowingFunctionName(t *testing.T) {
 		t.Errorf("expected %s to resolve to %+v, got=%+v", expected.Name, expected, result)
 	}
 }
+
+func TestDefineConst(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineConst("b")
+
+	local := NewEnclosedSymbolTable(global)
+	local.DefineConst("c")
+
+	nested := NewEnclosedSymbolTable(local)
+
+	expected := []struct {
+		table  *SymbolTable
+		symbol Symbol
+	}{
+		{global, Symbol{Name: "a", Scope: GlobalScope, Index: 0, ScopeIndex: 0}},
+		{global, Symbol{Name: "b", Scope: GlobalScope, Index: 1, ScopeIndex: 0, Immutable: true}},
+		{local, Symbol{Name: "c", Scope: LocalScope, Index: 0, ScopeIndex: 1, Immutable: true}},
+		{nested, Symbol{Name: "c", Scope: FreeScope, Index: 0, ScopeIndex: 1, Immutable: true}},
+	}
+
+	for _, tt := range expected {
+		result, ok := tt.table.Resolve(tt.symbol.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.symbol.Name)
+			continue
+		}
+
+		if result != tt.symbol {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.symbol.Name, tt.symbol, result)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+
+	global.Define("a")
+	global.Define("b")
+
+	global.Restore("a", a, true)
+	global.Restore("b", Symbol{}, false)
+
+	result, ok := global.Resolve("a")
+	if !ok {
+		t.Fatalf("name a not resolvable")
+	}
+
+	if result != a {
+		t.Errorf("expected a to resolve to %+v, got=%+v", a, result)
+	}
+
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("name b resolved but expected not to")
+	}
+}
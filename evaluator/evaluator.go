@@ -4,6 +4,8 @@ import (
 	"cidoka/ast"
 	"cidoka/object"
 	"fmt"
+	"math"
+	"strings"
 )
 
 var (
@@ -31,21 +33,43 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	// Statements
 	case *ast.LetStatement:
+		if node.Pattern != nil {
+			return evalDestructuringLet(node, env)
+		}
+
 		_, ok := env.GetNoRecursion(node.Name.Value)
 		if ok && !env.IsLoop() {
 			return newError("identifier already declared: " + node.Name.Value)
 		}
 
 		val := Eval(node.Value, env)
-		if isError(val) {
+		if isInterrupt(val) {
 			return val
 		}
 
-		env.Set(node.Name.Value, val)
+		if node.IsConst() {
+			env.SetConst(node.Name.Value, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
+
+	case *ast.FunctionDeclaration:
+		fn := node.Function
+		declared := &object.Function{Parameters: fn.Parameters, Defaults: fn.Defaults, Rest: fn.Rest, Env: env, Body: fn.Body}
+
+		// Fill in the placeholder hoisted for the declaration, so aliases taken before it see the function too
+		if placeholder, ok := env.GetNoRecursion(node.Name.Value); ok {
+			if placeholder, ok := placeholder.(*object.Function); ok && placeholder.Body == nil {
+				*placeholder = *declared
+				break
+			}
+		}
+
+		env.Set(node.Name.Value, declared)
 
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if isInterrupt(val) {
 			return val
 		}
 
@@ -78,12 +102,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		for isTruthy(condition) {
 			// Evaluate the body
 			body := Eval(node.Body, loopEnv)
-			if isError(body) {
-				return body
-			}
-
-			if body == BREAK {
-				break
+			if stop, result := evalLoopControl(node.Label, body); stop {
+				return result
 			}
 
 			// Evaluate the update expression
@@ -99,10 +119,21 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			}
 		}
 
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+
 	case *ast.BreakStatement:
+		if node.Label != nil {
+			return &object.Break{Label: node.Label.Value}
+		}
+
 		return BREAK
 
 	case *ast.ContinueStatement:
+		if node.Label != nil {
+			return &object.Continue{Label: node.Label.Value}
+		}
+
 		return CONTINUE
 
 	// Expressions
@@ -111,10 +142,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.AssignExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isInterrupt(right) {
 			return right
 		}
 
+		if node.Pattern != nil {
+			return evalDestructuringAssignment(node.Pattern, right, env)
+		}
+
 		return evalAssignExpression(node.Operator, node.Left, right, env)
 
 	case *ast.IntegerLiteral:
@@ -126,12 +161,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
+	case *ast.NullLiteral:
+		return NULL
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isInterrupt(right) {
 			return right
 		}
 
@@ -139,12 +180,21 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isInterrupt(left) {
 			return left
 		}
 
+		// the right side of ?? is only evaluated if the left one is null
+		if node.Operator == "??" {
+			if left != nil && left.Type() != object.NULL_OBJ {
+				return left
+			}
+
+			return Eval(node.Right, env)
+		}
+
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if isInterrupt(right) {
 			return right
 		}
 
@@ -153,30 +203,43 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.PostfixExpression:
 		return evalPostfixExpression(node.Operator, node.Left, env)
 
+	case *ast.RangeExpression:
+		return evalRangeExpression(node, env)
+
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Body: body, Env: env}
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
-		if isError(function) {
+		if isInterrupt(function) {
 			return function
 		}
 
 		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		if len(args) == 1 && isInterrupt(args[0]) {
 			return args[0]
 		}
 
+		if len(node.Keywords) > 0 {
+			args = evalKeywordArguments(function, args, node.Keywords, env)
+			if len(args) == 1 && isInterrupt(args[0]) {
+				return args[0]
+			}
+		}
+
 		return applyFunction(function, args)
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && isInterrupt(elements[0]) {
 			return elements[0]
 		}
 
@@ -185,18 +248,27 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 
+	case *ast.ArrayComprehension:
+		return evalArrayComprehension(node, env)
+
+	case *ast.HashComprehension:
+		return evalHashComprehension(node, env)
+
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if isInterrupt(left) {
 			return left
 		}
 
 		index := Eval(node.Index, env)
-		if isError(index) {
+		if isInterrupt(index) {
 			return index
 		}
 
 		return evalIndexExpression(left, index)
+
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	}
 
 	return nil
@@ -205,10 +277,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
+	if err := hoistFunctionDeclarations(program.Statements, env); err != nil {
+		return err
+	}
+
 	for _, stmt := range program.Statements {
 		result = Eval(stmt, env)
 
-		switch result := result.(type) {
+		switch result := unknownLabelError(result).(type) {
 		case *object.ReturnValue:
 			return result.Value
 		case *object.Error:
@@ -222,6 +298,10 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
+	if err := hoistFunctionDeclarations(block.Statements, env); err != nil {
+		return err
+	}
+
 	for _, stmt := range block.Statements {
 		result = Eval(stmt, env)
 
@@ -241,6 +321,194 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+// Defines the names of the functions declared in a list of statements, so they can be used before their declaration
+// Each name holds an empty function until its declaration runs
+func hoistFunctionDeclarations(statements []ast.Statement, env *object.Environment) object.Object {
+	for _, stmt := range statements {
+		decl, ok := stmt.(*ast.FunctionDeclaration)
+		if !ok {
+			continue
+		}
+
+		_, ok = env.GetNoRecursion(decl.Name.Value)
+		if ok && !env.IsLoop() {
+			return newError("identifier already declared: " + decl.Name.Value)
+		}
+
+		env.Set(decl.Name.Value, &object.Function{})
+	}
+
+	return nil
+}
+
+func evalForInStatement(loop *ast.ForInStatement, env *object.Environment) object.Object {
+	return evalForIn(loop.Label, loop.Index, loop.Element, loop.Iterable, env, func(loopEnv *object.Environment) object.Object {
+		return Eval(loop.Body, loopEnv)
+	})
+}
+
+// Evaluates a for-in loop over iterable, binding index (or nil) and element on every iteration before evaluating the body
+func evalForIn(label, index, element *ast.Identifier, iterableNode ast.Expression, env *object.Environment, evalBody func(*object.Environment) object.Object) object.Object {
+	obj := Eval(iterableNode, env)
+	if isError(obj) {
+		return obj
+	}
+
+	iterable, ok := obj.(object.Iterable)
+	if !ok {
+		return newError("cannot iterate over %s", obj.Type())
+	}
+
+	iterator := iterable.Iterator()
+
+	for {
+		key, value, ok := iterator.Next()
+		if !ok {
+			break
+		}
+
+		// Every iteration gets its own variables, so closures created in the body keep their values
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.SetLoop(true)
+
+		if index != nil {
+			loopEnv.Set(index.Value, key)
+			loopEnv.Set(element.Value, value)
+		} else {
+			loopEnv.Set(element.Value, object.LoopVariable(iterator, key, value))
+		}
+
+		body := evalBody(loopEnv)
+		if stop, result := evalLoopControl(label, body); stop {
+			return result
+		}
+	}
+
+	return nil
+}
+
+func evalArrayComprehension(node *ast.ArrayComprehension, env *object.Environment) object.Object {
+	elements := []object.Object{}
+
+	result := evalComprehension(node.Clause, env, func(loopEnv *object.Environment) object.Object {
+		// the element may be a spread adding several elements
+		values := evalExpressions([]ast.Expression{node.Element}, loopEnv)
+		if len(values) == 1 && isInterrupt(values[0]) {
+			return values[0]
+		}
+
+		elements = append(elements, values...)
+		return nil
+	})
+	if result != nil {
+		return result
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func evalHashComprehension(node *ast.HashComprehension, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	result := evalComprehension(node.Clause, env, func(loopEnv *object.Environment) object.Object {
+		key := Eval(node.Key, loopEnv)
+		if isInterrupt(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(node.Value, loopEnv)
+		if isInterrupt(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+		return nil
+	})
+	if result != nil {
+		return result
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
+
+// Evaluates the for clause of a comprehension, calling addEntry in the environment of every iteration whose condition holds
+// It returns nil once the loop is done, or the error, or return value, that stopped it
+func evalComprehension(clause *ast.ComprehensionClause, env *object.Environment, addEntry func(*object.Environment) object.Object) object.Object {
+	return evalForIn(nil, clause.Index, clause.Element, clause.Iterable, env, func(loopEnv *object.Environment) object.Object {
+		if clause.Condition != nil {
+			condition := Eval(clause.Condition, loopEnv)
+			if isInterrupt(condition) {
+				return condition
+			}
+
+			if !isTruthy(condition) {
+				return nil
+			}
+		}
+
+		return addEntry(loopEnv)
+	})
+}
+
+// Reports whether obj interrupts the evaluation of the code it came from: an error, a return value, a break or a continue
+func isInterrupt(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+		return true
+	}
+
+	return false
+}
+
+// Reports whether a loop must stop after its body evaluated to body, and what the loop then evaluates to
+// A break or continue naming an outer loop stops the loop and is passed on to the loops around it
+func evalLoopControl(label *ast.Identifier, body object.Object) (bool, object.Object) {
+	targets := func(name string) bool {
+		return name == "" || (label != nil && label.Value == name)
+	}
+
+	switch body := body.(type) {
+	case *object.Error, *object.ReturnValue:
+		// A return statement returns from the function the loop is in
+		return true, body
+	case *object.Break:
+		if targets(body.Label) {
+			return true, nil
+		}
+
+		return true, body
+	case *object.Continue:
+		if targets(body.Label) {
+			return false, nil
+		}
+
+		return true, body
+	}
+
+	return false, nil
+}
+
+// Turns a labeled break or continue that left every loop into an error
+func unknownLabelError(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Break:
+		if obj.Label != "" {
+			return newError("unknown label %s", obj.Label)
+		}
+	case *object.Continue:
+		if obj.Label != "" {
+			return newError("unknown label %s", obj.Label)
+		}
+	}
+
+	return obj
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -255,6 +523,8 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalBitNotPrefixOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -286,8 +556,25 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	}
 }
 
+func evalBitNotPrefixOperatorExpression(right object.Object) object.Object {
+	if right.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: ~%s", right.Type())
+	}
+
+	value := right.(*object.Integer).Value
+	return &object.Integer{Value: ^value}
+}
+
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
+	case operator == "in":
+		found, err := object.Contains(right, left)
+		if err != nil {
+			return newError("%s", err)
+		}
+
+		return nativeBoolToBooleanObject(found)
+
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
@@ -295,6 +582,12 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 
+	// null is only equal to itself
+	case operator == "==" && (left.Type() == object.NULL_OBJ || right.Type() == object.NULL_OBJ):
+		return nativeBoolToBooleanObject(left.Type() == right.Type())
+	case operator == "!=" && (left.Type() == object.NULL_OBJ || right.Type() == object.NULL_OBJ):
+		return nativeBoolToBooleanObject(left.Type() != right.Type())
+
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -313,24 +606,26 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 func evalAssignExpression(operator string, left ast.Expression, right object.Object, env *object.Environment) object.Object {
 	switch left := left.(type) {
 	case *ast.Identifier:
-		oldVal, identEnv, ok := env.Get(left.Value)
+		oldVal, _, ok := env.Get(left.Value)
 		if !ok {
 			return newError("identifier not found: " + left.Value)
 		}
 
 		newVal := handleAssignValue(oldVal, right, operator)
-		identEnv.Set(left.Value, newVal)
+		if err := env.Assign(left.Value, newVal); err != nil {
+			return newError("%s", err)
+		}
 
 		return newVal
 
 	case *ast.IndexExpression:
 		leftVal := Eval(left.Left, env)
-		if isError(leftVal) {
+		if isInterrupt(leftVal) {
 			return leftVal
 		}
 
 		index := Eval(left.Index, env)
-		if isError(index) {
+		if isInterrupt(index) {
 			return index
 		}
 
@@ -343,10 +638,17 @@ func evalAssignExpression(operator string, left ast.Expression, right object.Obj
 
 		switch leftVal := leftVal.(type) {
 		case *object.Array:
-			leftVal.Elements[index.(*object.Integer).Value] = newVal
+			idx, ok := object.ResolveIndex(index.(*object.Integer).Value, int64(len(leftVal.Elements)))
+			if !ok {
+				return newError("index out of range: %d", index.(*object.Integer).Value)
+			}
+
+			leftVal.Elements[idx] = newVal
 		case *object.Hash:
 			hash := leftVal
 			hash.Pairs[index.(object.Hashable).HashKey()] = object.HashPair{Key: index, Value: newVal}
+		default:
+			return newError("index operator not supported: %s", leftVal.Type())
 		}
 
 		return newVal
@@ -356,6 +658,41 @@ func evalAssignExpression(operator string, left ast.Expression, right object.Obj
 	}
 }
 
+func evalDestructuringLet(node *ast.LetStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isInterrupt(val) {
+		return val
+	}
+
+	return destructure(node.Pattern, val, func(name string, val object.Object) object.Object {
+		if _, ok := env.GetNoRecursion(name); ok && !env.IsLoop() {
+			return newError("identifier already declared: " + name)
+		}
+
+		if node.IsConst() {
+			env.SetConst(name, val)
+		} else {
+			env.Set(name, val)
+		}
+		return nil
+	})
+}
+
+func evalDestructuringAssignment(pattern ast.Pattern, right object.Object, env *object.Environment) object.Object {
+	err := destructure(pattern, right, func(name string, val object.Object) object.Object {
+		if err := env.Assign(name, val); err != nil {
+			return newError("%s", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return right
+}
+
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
@@ -371,6 +708,20 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 		return &object.Integer{Value: leftVal / rightVal}
 	case "%":
 		return &object.Integer{Value: leftVal % rightVal}
+	case "**":
+		power, err := object.IntegerPow(leftVal, rightVal)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return &object.Integer{Value: power}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<", ">>":
+		return evalShiftExpression(operator, leftVal, rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -388,6 +739,18 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+func evalShiftExpression(operator string, leftVal, rightVal int64) object.Object {
+	if rightVal < 0 {
+		return newError("negative shift count: %d", rightVal)
+	}
+
+	if operator == "<<" {
+		return &object.Integer{Value: leftVal << rightVal}
+	}
+
+	return &object.Integer{Value: leftVal >> rightVal}
+}
+
 func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.Float).Value
 	rightVal := right.(*object.Float).Value
@@ -401,6 +764,8 @@ func evalFloatInfixExpression(operator string, left, right object.Object) object
 		return &object.Float{Value: leftVal * rightVal}
 	case "/":
 		return &object.Float{Value: leftVal / rightVal}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -419,13 +784,19 @@ func evalFloatInfixExpression(operator string, left, right object.Object) object
 }
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
-
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
 func evalPostfixExpression(operator string, left ast.Expression, env *object.Environment) object.Object {
@@ -442,7 +813,7 @@ func evalPostfixExpression(operator string, left ast.Expression, env *object.Env
 		return evalAssignExpression(op, left, right, env)
 	default:
 		leftExpr := Eval(left, env)
-		if isError(leftExpr) {
+		if isInterrupt(leftExpr) {
 			return leftExpr
 		}
 
@@ -470,7 +841,7 @@ func evalPostfixExpression(operator string, left ast.Expression, env *object.Env
 
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+	if isInterrupt(condition) {
 		return condition
 	}
 
@@ -483,6 +854,188 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(me.Subject, env)
+	if isInterrupt(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		// names bound by the pattern are only visible in the arm
+		armEnv := object.NewEnclosedEnvironment(env)
+
+		matched := matchPattern(arm.Pattern, subject, armEnv)
+		if isError(matched) {
+			return matched
+		}
+		if matched != TRUE {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isInterrupt(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		result := Eval(arm.Body, armEnv)
+		if result == nil {
+			return NULL
+		}
+
+		return result
+	}
+
+	return NULL
+}
+
+// Matches value against pattern, binding names into env, and returns TRUE, FALSE or an error
+func matchPattern(pattern ast.Pattern, value object.Object, env *object.Environment) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return TRUE
+
+	case *ast.BindingPattern:
+		env.Set(pattern.Name.Value, value)
+		return TRUE
+
+	case *ast.LiteralPattern:
+		literal := Eval(pattern.Value, env)
+		if isInterrupt(literal) {
+			return literal
+		}
+
+		return evalInfixExpression("==", value, literal)
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok || len(array.Elements) < len(pattern.Elements) {
+			return FALSE
+		}
+		if pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+			return FALSE
+		}
+
+		for i, element := range pattern.Elements {
+			matched := matchPattern(element, array.Elements[i], env)
+			if matched != TRUE {
+				return matched
+			}
+		}
+
+		if pattern.Rest != nil {
+			return matchPattern(pattern.Rest, arrayRest(array, len(pattern.Elements)), env)
+		}
+
+		return TRUE
+
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return FALSE
+		}
+
+		for i, keyNode := range pattern.Keys {
+			key := Eval(keyNode, env)
+			if isInterrupt(key) {
+				return key
+			}
+
+			hashKey, ok := key.(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", key.Type())
+			}
+
+			pair, ok := hash.Pairs[hashKey.HashKey()]
+			if !ok {
+				return FALSE
+			}
+
+			matched := matchPattern(pattern.Values[i], pair.Value, env)
+			if matched != TRUE {
+				return matched
+			}
+		}
+
+		return TRUE
+
+	default:
+		return newError("unknown pattern: %s", pattern)
+	}
+}
+
+/*
+Destructures value into the names bound by pattern, calling bind for each of them
+
+Elements and keys missing from value are bound to null. It returns an error if value can't be
+destructured, nil otherwise
+*/
+func destructure(pattern ast.Pattern, value object.Object, bind func(name string, val object.Object) object.Object) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return nil
+
+	case *ast.BindingPattern:
+		return bind(pattern.Name.Value, value)
+
+	case *ast.ArrayPattern:
+		for i, element := range pattern.Elements {
+			val := evalIndexExpression(value, &object.Integer{Value: int64(i)})
+			if isError(val) {
+				return val
+			}
+
+			if err := destructure(element, val, bind); err != nil {
+				return err
+			}
+		}
+
+		if rest, ok := pattern.Rest.(*ast.BindingPattern); ok {
+			array, ok := value.(*object.Array)
+			if !ok {
+				return newError("rest element not supported: %s", value.Type())
+			}
+
+			return bind(rest.Name.Value, arrayRest(array, len(pattern.Elements)))
+		}
+
+		return nil
+
+	case *ast.HashPattern:
+		for i, keyNode := range pattern.Keys {
+			// hash pattern keys are literals, evaluating them needs no environment
+			key := Eval(keyNode, nil)
+
+			val := evalIndexExpression(value, key)
+			if isError(val) {
+				return val
+			}
+
+			if err := destructure(pattern.Values[i], val, bind); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	default:
+		return newError("literal pattern %s can't be used to destructure a value", pattern)
+	}
+}
+
+func arrayRest(array *object.Array, start int) *object.Array {
+	elements := []object.Object{}
+	if start < len(array.Elements) {
+		elements = append(elements, array.Elements[start:]...)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, _, ok := env.Get(node.Value); ok {
 		return val
@@ -524,8 +1077,23 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	var result []object.Object
 
 	for _, e := range exps {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			value := Eval(spread.Value, env)
+			if isInterrupt(value) {
+				return []object.Object{value}
+			}
+
+			elements, err := object.SpreadElements(value)
+			if err != nil {
+				return []object.Object{newError("%s", err)}
+			}
+
+			result = append(result, elements...)
+			continue
+		}
+
 		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		if isInterrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -534,10 +1102,56 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	parts := evalExpressions(node.Parts, env)
+	if len(parts) == 1 && isInterrupt(parts[0]) {
+		return parts[0]
+	}
+
+	var out strings.Builder
+	for _, part := range parts {
+		out.WriteString(object.Stringify(part))
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func evalRangeExpression(node *ast.RangeExpression, env *object.Environment) object.Object {
+	bounds := []ast.Expression{node.Start, node.End}
+	if node.Step != nil {
+		bounds = append(bounds, node.Step)
+	}
+
+	values := evalExpressions(bounds, env)
+	if len(values) == 1 && isInterrupt(values[0]) {
+		return values[0]
+	}
+
+	var step object.Object
+	if len(values) == 3 {
+		step = values[2]
+	}
+
+	rng, err := object.NewRange(values[0], values[1], step)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return rng
+}
+
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		if fn.Body == nil {
+			return newError("calling a function before its declaration")
+		}
+
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 
@@ -552,13 +1166,89 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// Evaluates the keyword arguments of a call and returns all the arguments in parameter order
+// Parameters that weren't passed are left nil
+func evalKeywordArguments(fn object.Object, positional []object.Object, keywords []*ast.KeywordArgument, env *object.Environment) []object.Object {
+	var params []string
+	var required int
+
+	switch fn := fn.(type) {
+	case *object.Function:
+		for i, param := range fn.Parameters {
+			params = append(params, param.Value)
+			if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+				required++
+			}
+		}
+	case *object.Builtin:
+		if fn.Parameters == nil {
+			return []object.Object{newError("builtin function doesn't accept keyword arguments")}
+		}
+		params = fn.Parameters
+		required = len(fn.Parameters)
+	default:
+		return []object.Object{newError("not a function: %s", fn.Type())}
+	}
+
+	names := []string{}
+	values := []object.Object{}
+	for _, arg := range keywords {
+		value := Eval(arg.Value, env)
+		if isInterrupt(value) {
+			return []object.Object{value}
+		}
+
+		names = append(names, arg.Name.Value)
+		values = append(values, value)
+	}
+
+	args, err := object.MatchKeywordArguments(params, required, positional, names, values)
+	if err != nil {
+		return []object.Object{newError("%s", err)}
+	}
+
+	return args
+}
+
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+	required := 0
+	for i := range fn.Parameters {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required++
+		}
+	}
+
+	if len(args) < required || fn.Rest == nil && len(args) > len(fn.Parameters) {
+		want := object.DescribeArity(required, len(fn.Parameters), fn.Rest != nil)
+		return nil, newError("wrong number of arguments: want=%s, got=%d", want, len(args))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for i, param := range fn.Parameters {
-		env.Set(param.Value, args[i])
+		if i < len(args) && args[i] != nil {
+			env.Set(param.Value, args[i])
+			continue
+		}
+
+		// default values are evaluated at call time and can refer to the parameters before them
+		val := Eval(fn.Defaults[i], env)
+		if isError(val) {
+			return nil, val
+		}
+
+		env.Set(param.Value, val)
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -566,13 +1256,22 @@ func unwrapReturnValue(obj object.Object) object.Object {
 		return returnValue.Value
 	}
 
-	return obj
+	// a function whose body produces no value returns null, as in the vm
+	if obj == nil {
+		return NULL
+	}
+
+	return unknownLabelError(obj)
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.RANGE_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalRangeIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -582,22 +1281,83 @@ func evalIndexExpression(left, index object.Object) object.Object {
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	idx, ok := object.ResolveIndex(index.(*object.Integer).Value, int64(len(arrayObject.Elements)))
 
-	if idx < 0 || idx > max {
+	if !ok {
 		return NULL
 	}
 
 	return arrayObject.Elements[idx]
 }
 
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx, ok := object.ResolveIndex(index.(*object.Integer).Value, int64(len(runes)))
+
+	if !ok {
+		return NULL
+	}
+
+	return &object.String{Value: string(runes[idx])}
+}
+
+func evalRangeIndexExpression(rng, index object.Object) object.Object {
+	rangeObject := rng.(*object.Range)
+	idx, ok := object.ResolveIndex(index.(*object.Integer).Value, rangeObject.Len())
+
+	if !ok {
+		return NULL
+	}
+
+	return rangeObject.At(idx)
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isInterrupt(left) {
+		return left
+	}
+
+	// a missing bound stays nil
+	bounds := []object.Object{nil, nil}
+	for i, bound := range []ast.Expression{node.Start, node.End} {
+		if bound == nil {
+			continue
+		}
+
+		bounds[i] = Eval(bound, env)
+		if isInterrupt(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	slice, err := object.Slice(left, bounds[0], bounds[1])
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return slice
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Entries() {
+		if spread, ok := keyNode.(*ast.SpreadExpression); ok {
+			value := Eval(spread.Value, env)
+			if isInterrupt(value) {
+				return value
+			}
+
+			if err := object.SpreadPairs(&object.Hash{Pairs: pairs}, value); err != nil {
+				return newError("%s", err)
+			}
+
+			continue
+		}
+
 		key := Eval(keyNode, env)
-		if isError(key) {
+		if isInterrupt(key) {
 			return key
 		}
 
@@ -606,8 +1366,8 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := Eval(node.Pairs[keyNode], env)
+		if isInterrupt(value) {
 			return value
 		}
 
@@ -648,6 +1408,18 @@ func handleAssignValue(oldVal object.Object, val object.Object, operator string)
 		newVal = evalInfixExpression("/", oldVal, val)
 	case "%=":
 		newVal = evalInfixExpression("%", oldVal, val)
+	case "**=":
+		newVal = evalInfixExpression("**", oldVal, val)
+	case "&=":
+		newVal = evalInfixExpression("&", oldVal, val)
+	case "|=":
+		newVal = evalInfixExpression("|", oldVal, val)
+	case "^=":
+		newVal = evalInfixExpression("^", oldVal, val)
+	case "<<=":
+		newVal = evalInfixExpression("<<", oldVal, val)
+	case ">>=":
+		newVal = evalInfixExpression(">>", oldVal, val)
 	}
 
 	return newVal
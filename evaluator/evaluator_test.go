@@ -25,6 +25,12 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"2 * 3 ** 2", 18},
+		{"let a = 3; a **= 2; a", 9},
+		{"let a = [2]; a[0] **= 3; a[0]", 8},
 	}
 
 	for _, tt := range tests {
@@ -53,6 +59,8 @@ func TestEvalFloatExpression(t *testing.T) {
 		{"2.5 * (5.5 + 10.5)", 40},
 		{"3.5 * 3.5 * 3.5 + 10.5", 53.375},
 		{"3.5 * (3.5 * 3.5) + 10.5", 53.375},
+		{"2.0 ** 0.5", 1.4142135623730951},
+		{"4.0 ** -1.0", 0.25},
 	}
 
 	for _, tt := range tests {
@@ -93,6 +101,9 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"true || false", true},
 		{"false || true", true},
 		{"false || false", false},
+		{`"mon" + "key" == "monkey"`, true},
+		{`"monkey" == "donkey"`, false},
+		{`"monkey" != "donkey"`, true},
 	}
 
 	for _, tt := range tests {
@@ -156,6 +167,109 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestNull(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"null", nil},
+		{"null == null", true},
+		{"null != null", false},
+		{"1 == null", false},
+		{"null != 1", true},
+		{`[1][5] == null`, true},
+		{`{"a": 1}["b"] == null`, true},
+		{`fn() {}() == null`, true},
+		{`!null`, true},
+		{`null ?? 5`, 5},
+		{`0 ?? 5`, 0},
+		{`false ?? 5`, false},
+		{`null ?? null ?? 7`, 7},
+		{`let cfg = {"port": 80}; cfg["port"] ?? 8080`, 80},
+		{`let calls = 0; let f = fn() { calls += 1; 1 }; 2 ?? f(); calls`, 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`match (1) { 1 => 10, _ => 20 }`, 10},
+		{`match (2) { 1 => 10, _ => 20 }`, 20},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{`match (-2) { -2 => true }`, true},
+		{`match (null) { 0 => 0, null => 1 }`, 1},
+		{`match (5) { 1 => 1 }`, nil},
+		{`match (5) { 5 => {} }`, nil},
+		{`match ([1, [2, 3]]) { [a] => a, [a, [b, c]] => a + b + c }`, 6},
+		{`match ([1, 2]) { [a, b, c] => 0, [_, b] => b }`, 2},
+		{`match ({"type": "circle", "r": 2}) { {"type": "square", "side": s} => s, {"type": "circle", "r": r} => r * 3 }`, 6},
+		{`match (1) { [] => 1, {} => 2, _ => 3 }`, 3},
+		{`match (4) { n if n > 5 => 3, n if n > 2 => 2, _ => 1 }`, 2},
+		{`let x = 10; match (1) { x => x }; x`, 10},
+		{`let f = fn(l) { match (l) { [h, t] => h + f(t), [] => 0 } }; f([1, [2, [3, []]]])`, 6},
+		{`let g = fn(x) { match (x) { n => fn() { n * 2 } } }; g(21)()`, 42},
+		{`let f = fn() { match (1) { 1 => { return 5; } }; 0 }; f()`, 5},
+		{`let r = 0; for (let i = 0; i < 4; i++) { match (i) { 2 => { break; }, _ => { r += 1 } } } r`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let [a, b, ...rest] = [1, 2, 3, 4]; [a, b, rest]`, "[1, 2, [3, 4]]"},
+		{`let [a, ...rest] = [1]; rest`, "[]"},
+		{`let [x, [y, z]] = [1, [2]]; [x, y, z]`, "[1, 2, null]"},
+		{`let [_, second] = [1, 2]; second`, "2"},
+		{`let {name, age} = {"name": "Ana", "age": 30}; "${name} ${age}"`, "Ana 30"},
+		{`let {"k": v, 1: w} = {"k": 1, 1: 2}; v + w`, "3"},
+		{`let pair = fn() { [3, 4] }; let [a, b] = pair(); a * b`, "12"},
+		{`let a = 1; let b = 2; [a, b] = [b, a]; [a, b]`, "[2, 1]"},
+		{`let a = 0; let b = 0; {"x": a, "y": b} = {"x": 5, "y": 6}; a * b`, "30"},
+		{`match ([1, 2, 3]) { [h, ...t] => t, _ => 0 }`, "[2, 3]"},
+		{`let [a] = 5;`, "ERROR: index operator not supported: INTEGER"},
+		{`let a = 1; let [a] = [2];`, "ERROR: identifier already declared: a"},
+		{`[nope] = [1]`, "ERROR: identifier not found: nope"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -251,6 +365,26 @@ func TestErrorHandling(t *testing.T) {
 			`999[1]`,
 			"index operator not supported: INTEGER",
 		},
+		{
+			"1.5 & 1.5",
+			"unknown operator: FLOAT & FLOAT",
+		},
+		{
+			"1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			"2 ** -1",
+			"negative exponent: -1",
+		},
+		{
+			"10 ** 19",
+			"integer overflow: 10 ** 19",
+		},
+		{
+			`~"a"`,
+			"unknown operator: ~STRING",
+		},
 	}
 
 	for _, tt := range tests {
@@ -284,6 +418,33 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const one = 1; one", "1"},
+		{"const [a, b] = [1, 2]; a + b", "3"},
+		{"const xs = [1, 2]; xs[0] = 5; xs", "[5, 2]"},
+		{"const x = 1; let f = fn() { let x = 2; x = 3; x }; f() + x", "4"},
+		{"let r = []; for (i in 0..3) { const sq = i * i; r = push(r, sq) } r", "[0, 1, 4]"},
+		{"const a = 1; a = 2", "ERROR: cannot assign to constant a"},
+		{"const a = 1; a += 2", "ERROR: cannot assign to constant a"},
+		{"const a = 1; a++", "ERROR: cannot assign to constant a"},
+		{"const a = 1; let f = fn() { a = 2 }; f()", "ERROR: cannot assign to constant a"},
+		{"let b = 1; const [a] = [1]; [b, a] = [2, 3]", "ERROR: cannot assign to constant a"},
+		{"const a = 1; const a = 2", "ERROR: identifier already declared: a"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
@@ -326,6 +487,230 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(a, b = 2) { a + b }; f(1)`, "3"},
+		{`let f = fn(a, b = 2) { a + b }; f(1, 5)`, "6"},
+		{`let f = fn(a = 1, b = a * 2) { [a, b] }; f()`, "[1, 2]"},
+		{`let f = fn(a = 1, b = a * 2) { [a, b] }; f(3)`, "[3, 6]"},
+		{`let x = 1; let f = fn(a = x) { a }; x = 7; f()`, "7"},
+		{`let make = fn(n) { fn(a = n) { a } }; make(4)()`, "4"},
+		{`let f = fn(...rest) { rest }; f()`, "[]"},
+		{`let f = fn(a, ...rest) { [a, rest] }; f(1, 2, 3)`, "[1, [2, 3]]"},
+		{`let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 3, 5)`, "[1, 3, [5]]"},
+		{`let b = 10; let f = fn(a = b, b = 1, c = a + b) { [a, b, c] }; [f(), f(b: 2)]`, "[[10, 1, 11], [10, 2, 12]]"},
+		{`let f = fn(a = b, b = 1) { a + 1 }; f()`, "ERROR: identifier not found: b"},
+		{`let g = fn(x, y, z) { x }; g(1, 2, 3); let f = fn(a = b, b = 1) { a }; f()`, "ERROR: identifier not found: b"},
+		{`fn(a) { a }()`, "ERROR: wrong number of arguments: want=1, got=0"},
+		{`fn(a, b = 1) { a }(1, 2, 3)`, "ERROR: wrong number of arguments: want=1 to 2, got=3"},
+		{`fn(a, ...rest) { a }()`, "ERROR: wrong number of arguments: want=at least 1, got=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn add(a, b) { a + b } add(1, 2)`, "3"},
+		{`fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } } fact(5)`, "120"},
+		{`
+		fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+		fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+		[isEven(10), isOdd(7)]
+		`, "[true, true]"},
+		{`
+		fn outer(x) {
+			fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+			fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+			isEven(x)
+		}
+		[outer(4), outer(5)]
+		`, "[true, false]"},
+		{`fn outer() { let g = fn() { h() }; fn h() { 7 } g() } outer()`, "7"},
+		{`early(); fn early() { 1 }`, "ERROR: calling a function before its declaration"},
+		{`let g = h; fn h() { 1 } g()`, "1"},
+		{`fn outer() { let fs = [h]; fn h(x) { x * 2 } fs[0](4) } outer()`, "8"},
+		{`fn f() { 1 } fn f() { 2 }`, "ERROR: identifier already declared: f"},
+		{`let f = 1; fn f() { 2 }`, "ERROR: identifier already declared: f"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestKeywordArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn(a, b) { a - b }; f(b: 1, a: 5)`, "4"},
+		{`let f = fn(a, b) { a - b }; f(5, b: 1)`, "4"},
+		{`let f = fn(a, b = 2, c = 3) { [a, b, c] }; f(1, c: 9)`, "[1, 2, 9]"},
+		{`let f = fn(a, b = a + 1, c = b + 1) { [a, b, c] }; f(c: 0, a: 1)`, "[1, 2, 0]"},
+		{`let f = fn(a, ...rest) { [a, rest] }; f(a: 1)`, "[1, []]"},
+		{`push(element: 3, array: [1, 2])`, "[1, 2, 3]"},
+		{`fn(a) { a }(b: 1)`, "ERROR: unknown keyword argument b"},
+		{`fn(a) { a }(1, a: 2)`, "ERROR: argument a passed more than once"},
+		{`fn(a, b) { a }(b: 2)`, "ERROR: missing argument for parameter a"},
+		{`print(value: 1)`, "ERROR: builtin function doesn't accept keyword arguments"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestInOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`2 in [1, 2, 3]`, "true"},
+		{`"c" in ["a", "b"]`, "false"},
+		{`null in [1, null]`, "true"},
+		{`let h = {"a": null}; ["a" in h, "b" in h, h["a"] != null]`, "[true, false, false]"},
+		{`"ell" in "hello"`, "true"},
+		{`[4 in 0..10:2, 5 in 0..10:2, 10 in 0..10]`, "[true, false, false]"},
+		{`let xs = [[1]]; let x = xs[0]; [x in xs, [1] in xs]`, "[true, false]"},
+		{`1 in "abc"`, "ERROR: unknown operator: INTEGER in STRING"},
+		{`[1] in {}`, "ERROR: unusable as hash key: ARRAY"},
+		{`1 in 5`, "ERROR: unknown operator: INTEGER in INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1, 2]; let b = [3]; [...a, ...b, 4]`, "[1, 2, 3, 4]"},
+		{`[0, ...1..4, ..."ab", ...[]]`, "[0, 1, 2, 3, a, b]"},
+		{`let d = {"port": 8080, "tls": false}; let h = {...d, "port": 80}; [h["port"], h["tls"]]`, "[80, false]"},
+		{`let d = {"port": 8080}; {"port": 80, ...d}["port"]`, "8080"},
+		{`let f = fn(a, b, c = 3) { [a, b, c] }; let args = [1, 2]; f(...args)`, "[1, 2, 3]"},
+		{`let f = fn(a, b, c = 3) { [a, b, c] }; f(...[1], 2, c: 4)`, "[1, 2, 4]"},
+		{`let f = fn(first, ...rest) { rest }; f(...0..5)`, "[1, 2, 3, 4]"},
+		{`let [a, ...b] = [0, 0]; [a, ...b] = [1, 2, 3]; b`, "[2, 3]"},
+		{`[0, ...1]`, "ERROR: cannot spread INTEGER, expected ARRAY, RANGE or STRING"},
+		{`{"a": 1, ...[1]}`, "ERROR: cannot spread ARRAY into a hash, expected HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestComprehensions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[x * 2 for x in [1, 2, 3]]`, "[2, 4, 6]"},
+		{`[x for x in 0..10 if x % 3 == 0]`, "[0, 3, 6, 9]"},
+		{`[i for i, c in "abc" if c != "b"]`, "[0, 2]"},
+		{`let rows = [[1, 2], [], [3]]; [...row for row in rows if len(row) > 0]`, "[1, 2, 3]"},
+		{`[[x * y for y in 1..3] for x in 1..3]`, "[[1, 2], [2, 4]]"},
+		{`let fs = [fn() { x } for x in 1..3]; [fs[0](), fs[1]()]`, "[1, 2]"},
+		{`let f = fn() { [if (x == 2) { return x } else { x } for x in 1..4] }; f()`, "2"},
+		{`let h = {k: k * k for k in 1..4 if k != 2}; [h[1], h[2], h[3]]`, "[1, null, 9]"},
+		{`let h = {"a": 1, "b": 2}; let inv = {v: k for k, v in h}; inv[2]`, "b"},
+		{`let x = "outer"; [x for x in [1]]; x`, "outer"},
+		{`let out = []; outer: for (y in 0..3) { out = push(out, [if (x == 1) { continue outer } else { x } for x in 0..3]) } out`, "[]"},
+		{`let out = []; outer: for (y in 0..3) { out = push(out, [if (y == 1) { continue outer } else { x } for x in 0..2]) } out`, "[[0, 1], [0, 1]]"},
+		{`let out = []; outer: for (y in 0..3) { out = push(out, [if (x == y) { break outer } else { x } for x in 0..3]) } out`, "[]"},
+		{`let out = []; for (y in 0..3) { out = push(out, [if (x == y) { continue } else { x } for x in 0..3]) } out`, "[[1, 2], [0, 2], [0, 1]]"},
+		{`let out = []; for (y in 0..3) { out = push(out, if (y == 1) { continue } else { y }) } out`, "[0, 2]"},
+		{`[x for x in 5]`, "ERROR: cannot iterate over INTEGER"},
+		{`{[x]: x for x in 1..3}`, "ERROR: unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestLoopControlInsideExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let out = []; for (y in 0..3) { out = push(out, 1 + if (y == 1) { continue } else { y }) } out`, "[1, 3]"},
+		{`let out = []; for (y in 0..3) { out = push(out, -if (y == 1) { continue } else { y }) } out`, "[0, -2]"},
+		{`let xs = [10, 20, 30]; let out = []; for (y in 0..3) { out = push(out, xs[if (y == 2) { break } else { y }]) } out`, "[10, 20]"},
+		{`fn() { let h = {"a": if (true) { return 5 } else { 1 }}; 0 }()`, "5"},
+		{`fn() { if (if (true) { return "cond" } else { true }) { 1 } else { 2 } }()`, "cond"},
+		{`fn() { match (if (true) { return "subject" } else { 1 }) { _ => 0 } }()`, "subject"},
+		{`fn() { [1, 2][0:if (true) { return 7 } else { 1 }] }()`, "7"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestPipelineOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3] |> len()`, "3"},
+		{`let double = fn(x) { x * 2 }; 3 |> double() |> double()`, "12"},
+		{`let sub = fn(a, b = 1) { a - b }; [10 |> sub(), 10 |> sub(4), 10 |> sub(b: 3)]`, "[9, 6, 7]"},
+		{`[1, 2] |> push(3) |> tail()`, "[2, 3]"},
+		{`[[1, 2] |> len() + 1, [1, 2, 3] |> len() ** 2]`, "[3, 9]"},
+		{`1 + 2 |> len()`, "ERROR: argument to `len` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestEnclosingEnvironment(t *testing.T) {
 	input := `
 	let first = 10;
@@ -371,6 +756,72 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\tb"`, "a\tb"},
+		{`"say \"hi\"\n"`, "say \"hi\"\n"},
+		{`"\u{63}ido" + "ka"`, "cidoka"},
+		{"`raw\n\\t`", "raw\n\\t"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ana"; "hello ${name}!"`, "hello Ana!"},
+		{`let items = [1, 2]; "${len(items)} items: ${items}"`, "2 items: [1, 2]"},
+		{`"${1 + 2} ${true} ${if (false) { 1 }}"`, "3 true null"},
+		{`let f = fn(x) { "<${x}>" }; "${f("a")}${f(1)}"`, "<a><1>"},
+		{`"outer ${"inner ${"deep"}"}"`, "outer inner deep"},
+		{`"\${not} $interpolated"`, "${not} $interpolated"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let año = 2024; año + 1`, 2025},
+		{`let cañón = fn(ñ) { ñ * 2 }; cañón(21)`, 42},
+		{`len("ñandú")`, 5},
+		{`len("😀")`, 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -496,6 +947,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -511,6 +970,43 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSliceAndStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-10:10]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][3:1]", "[]"},
+		{"let a = [1, 2]; let b = a[:]; b[0] = 9; a", "[1, 2]"},
+		{`"héllo"[0]`, "h"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[5]`, "null"},
+		{`"héllo"[2:]`, "llo"},
+		{`"héllo"[:-3]`, "hé"},
+		{"(0..10:3)[-1]", "9"},
+		{"(0..10:3)[1:]", "3..10:3"},
+		{"(0..10)[2:-5]", "2..5"},
+		{"let a = [1, 2, 3]; a[-1] = 9; a", "[1, 2, 9]"},
+		{"let a = [1, 2, 3]; a[3] = 9", "ERROR: index out of range: 3"},
+		{`let s = "ab"; s[0] = "c"`, "ERROR: index operator not supported: STRING"},
+		{`[1, 2]["a":]`, "ERROR: slice bounds must be INTEGER, got STRING"},
+		{"5[1:]", "ERROR: slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -722,6 +1218,28 @@ func TestEvalModulo(t *testing.T) {
 	testIntegerObject(t, testEval(input), 2)
 }
 
+func TestEvalBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0b1100 & 0b1010", 8},
+		{"0b1100 | 0b1010", 14},
+		{"0b1100 ^ 0b1010", 6},
+		{"~0", -1},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"0xFF & 1 << 4", 16},
+		{"let flags = 0; flags |= 1 << 3; flags ^= 0b1001; flags", 1},
+		{"let x = 0xF0; x &= 0x3C; x >>= 2; x <<= 1; x", 24},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestRecursiveFibonacci(t *testing.T) {
 	input := `
 	let fibonacci = fn(x) {
@@ -770,6 +1288,61 @@ func TestEvalWhileLoop(t *testing.T) {
 	testIntegerObject(t, testEval(input), 45)
 }
 
+func TestEvalForInLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = 0; for (x in [1, 2, 3]) { s = s + x } s`, "6"},
+		{`let r = []; for (i, x in ["a", "b"]) { r = push(r, "${i}${x}") } r`, "[0a, 1b]"},
+		{`let r = []; for (k in {"b": 2, "a": 1}) { r = push(r, k) } r`, "[a, b]"},
+		{`let s = 0; for (k, v in {"a": 1, "b": 2, "c": 3}) { if (k == "b") { continue } s = s + v } s`, "4"},
+		{`let r = []; for (ch in "héllo") { if (ch == "l") { break } r = push(r, ch) } r`, "[h, é]"},
+		{`let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x * 10 }) } [fs[0](), fs[1]()]`, "[10, 20]"},
+		{`fn find(arr, target) { for (i, x in arr) { if (x == target) { return i } } -1 } [find([5, 6, 7], 7), find([5], 9)]`, "[2, -1]"},
+		{`fn grid() { let c = 0; for (i in [1, 2, 3]) { for (j in [1, 2]) { c = c + i * j } } c } grid()`, "18"},
+		{`for (x in 5) { x }`, "ERROR: cannot iterate over INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRangeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0..5", "0..5"},
+		{"let n = 3; 1..n + 1:n - 1", "1..4:2"},
+		{"(0..10:3)[1]", "3"},
+		{"(0..10:3)[4]", "null"},
+		{"(10..0:-3)[3]", "1"},
+		{"let s = 0; for (i in 1..101) { s += i } s", "5050"},
+		{"let r = []; for (i, x in 10..0:-4) { r = push(r, i * 100 + x) } r", "[10, 106, 202]"},
+		{"[len(0..10:3), len(5..1), first(3..6), last(0..10:3)]", "[4, 0, 3, 9]"},
+		{"[tail(0..10:3), push(1..3, 9)]", "[3..10:3, [1, 2, 9]]"},
+		{"0..2:0", "ERROR: range step cannot be zero"},
+		{`"a"..2`, "ERROR: range bounds must be INTEGER, got STRING"},
+		{"len(-9223372036854775807..9223372036854775807)", "ERROR: range -9223372036854775807..9223372036854775807 is too long, it has more than 9223372036854775807 integers"},
+		{"push(0..9223372036854775807, 1)", "ERROR: range 0..9223372036854775807 is too long to build an array from, at most 16777216 integers allowed"},
+		{"[...0..9223372036854775807]", "ERROR: range 0..9223372036854775807 is too long to build an array from, at most 16777216 integers allowed"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestContinueStatement(t *testing.T) {
 	input := `
 	let sum = 0;
@@ -785,6 +1358,28 @@ func TestContinueStatement(t *testing.T) {
 	testIntegerObject(t, testEval(input), 25)
 }
 
+func TestLabeledLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let r = []; outer: for (let i = 0; i < 3; i += 1) { for (let j = 0; j < 3; j += 1) { if (j > i) { continue outer } if (i == 2) { break outer } r = push(r, [i, j]) } } r`, "[[0, 0], [1, 0], [1, 1]]"},
+		{`let found = null; rows: for (r, row in [[1, 2], [3, 4]]) { for (x in row) { if (x == 4) { found = [r, x]; break rows } } } found`, "[1, 4]"},
+		{`let c = 0; l: for (i in 0..3) { l: for (j in 0..3) { if (j == 1) { continue l } c += 1 } } c`, "6"},
+		{`let f = fn() { outer: while (true) { for (x in 1..10) { if (x == 3) { return x } } } }; f()`, "3"},
+		{`for (x in [1]) { break nope }`, "ERROR: unknown label nope"},
+		{`a: for (x in [1]) { let f = fn() { break a }; f() }`, "ERROR: unknown label a"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestArrayIndexReassignment(t *testing.T) {
 	input := `
 	let arr = [1, 2, 3];
@@ -2,31 +2,90 @@ package lexer
 
 import (
 	"cidoka/token"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
 	input        string // input to be tokenized
-	position     int    // current position in input (points to current char)
-	readPosition int    // current reading position in input (after current char)
-	ch           byte   // current char under examination
+	position     int    // current byte position in input (points to current char)
+	readPosition int    // current byte reading position in input (after current char)
+	ch           rune   // current char under examination
+	line         int    // line of the current char, starting at 1
+	column       int    // column of the current char in runes, starting at 1
+
+	illegal      map[int]string // why each ILLEGAL token was produced, keyed by the token's offset
+	unterminated map[int]bool   // ILLEGAL tokens cut short by the end of the input, keyed by the token's offset
+
+	interpolations []int // brace depth inside each string interpolation being lexed, innermost last
 }
 
 /* Returns a new Lexer instance fully initialized */
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1, illegal: make(map[int]string), unterminated: make(map[int]bool)}
 	l.readChar()
 	return l
 }
 
-/* Returns the next token from the input */
+/*
+Returns the reason why the lexer produced the given ILLEGAL token
+
+It returns false if the lexer didn't record a reason, e.g. for a lone unknown character
+*/
+func (l *Lexer) IllegalReason(tok token.Token) (string, bool) {
+	reason, ok := l.illegal[tok.Pos.Offset]
+	return reason, ok
+}
+
+/*
+Returns true if the given ILLEGAL token is a string, raw string or block comment that
+was never closed because the input ended, so more input could still complete it
+*/
+func (l *Lexer) Unterminated(tok token.Token) bool {
+	return tok.Type == token.ILLEGAL && l.unterminated[tok.Pos.Offset]
+}
+
+/* Records why the ILLEGAL token starting at offset was cut short by the end of the input */
+func (l *Lexer) markUnterminated(offset int, reason string) {
+	l.illegal[offset] = reason
+	l.unterminated[offset] = true
+}
+
+/*
+Returns the next token from the input
+
+Comments preceding the token are attached to it as trivia. An unterminated
+block comment is returned as an ILLEGAL token holding the comment's text
+*/
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	comments, unterminated := l.skipWhitespaceAndComments()
+	if unterminated != nil {
+		l.markUnterminated(unterminated.Pos.Offset, "unterminated block comment")
+		return token.Token{Type: token.ILLEGAL, Literal: unterminated.Text, Pos: unterminated.Pos, Comments: comments}
+	}
+
+	pos := l.currentPosition()
+
+	tok := l.readToken()
+	tok.Pos = pos
+	tok.Comments = comments
+
+	return tok
+}
 
-	l.skipWhitespace()
+/* Reads the token starting at the current character */
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
 		tok = l.compundableAssignment('=', token.ASSIGN, token.EQ)
+		if tok.Type == token.ASSIGN {
+			tok = l.compundableAssignment('>', token.ASSIGN, token.ARROW)
+		}
 	case '+':
 		tok = l.compundableAssignment('=', token.PLUS, token.PLUS_EQ)
 		if tok.Type == token.PLUS {
@@ -41,21 +100,51 @@ func (l *Lexer) NextToken() token.Token {
 		tok = l.compundableAssignment('=', token.BANG, token.NOT_EQ)
 	case '*':
 		tok = l.compundableAssignment('=', token.ASTERISK, token.ASTERISK_EQ)
+		if tok.Type == token.ASTERISK {
+			tok = l.compundableAssignment('*', token.ASTERISK, token.POWER)
+		}
+		if tok.Type == token.POWER {
+			tok = l.extendOperator(tok, '=', token.POWER_EQ)
+		}
 	case '/':
 		tok = l.compundableAssignment('=', token.SLASH, token.SLASH_EQ)
 	case '%':
 		tok = l.compundableAssignment('=', token.MODULO, token.MODULO_EQ)
 	case '<':
 		tok = l.compundableAssignment('=', token.LT, token.LT_EQ)
+		if tok.Type == token.LT {
+			tok = l.compundableAssignment('<', token.LT, token.SHL)
+		}
+		if tok.Type == token.SHL {
+			tok = l.extendOperator(tok, '=', token.SHL_EQ)
+		}
 	case '>':
 		tok = l.compundableAssignment('=', token.GT, token.GT_EQ)
+		if tok.Type == token.GT {
+			tok = l.compundableAssignment('>', token.GT, token.SHR)
+		}
+		if tok.Type == token.SHR {
+			tok = l.extendOperator(tok, '=', token.SHR_EQ)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		// a '}' closing an interpolation resumes the string it was embedded in
+		if n := len(l.interpolations); n > 0 {
+			if l.interpolations[n-1] == 0 {
+				l.interpolations = l.interpolations[:n-1]
+				tok = l.readStringPart(token.INTERP_MID, token.INTERP_TAIL)
+				break
+			}
+			l.interpolations[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
@@ -68,12 +157,28 @@ func (l *Lexer) NextToken() token.Token {
 	case ':':
 		tok = newToken(token.COLON, l.ch)
 	case '&':
-		tok = l.compundableAssignment('&', token.ILLEGAL, token.AND)
+		tok = l.compundableAssignment('=', token.BIT_AND, token.BIT_AND_EQ)
+		if tok.Type == token.BIT_AND {
+			tok = l.compundableAssignment('&', token.BIT_AND, token.AND)
+		}
 	case '|':
-		tok = l.compundableAssignment('|', token.ILLEGAL, token.OR)
+		tok = l.compundableAssignment('=', token.BIT_OR, token.BIT_OR_EQ)
+		if tok.Type == token.BIT_OR {
+			tok = l.compundableAssignment('|', token.BIT_OR, token.OR)
+		}
+		if tok.Type == token.BIT_OR {
+			tok = l.compundableAssignment('>', token.BIT_OR, token.PIPE)
+		}
+	case '^':
+		tok = l.compundableAssignment('=', token.BIT_XOR, token.BIT_XOR_EQ)
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
+	case '?':
+		tok = l.compundableAssignment('?', token.ILLEGAL, token.NULLISH)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readString()
+	case '`':
+		tok = l.readRawString()
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -92,6 +197,10 @@ func (l *Lexer) NextToken() token.Token {
 
 			return tok
 
+		// two dots are a range, e.g. 0..10, and three dots are an ellipsis, e.g. [first, ...rest]
+		case l.ch == '.' && l.peekChar() == '.':
+			tok = l.readDots()
+
 		// if it's none of the above, it's an illegal token
 		default:
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -102,6 +211,18 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+/* Reads a range operator, or an ellipsis if the two dots are followed by a third one */
+func (l *Lexer) readDots() token.Token {
+	l.readChar()
+
+	if l.peekChar() != '.' {
+		return token.Token{Type: token.RANGE, Literal: ".."}
+	}
+
+	l.readChar()
+	return token.Token{Type: token.ELLIPSIS, Literal: "..."}
+}
+
 /*
 Skips any whitespace characters in the input
 
@@ -114,28 +235,105 @@ func (l *Lexer) skipWhitespace() {
 }
 
 /*
-Reads the next character in the input and advances the position
+Skips whitespace and comments until the start of the next token
+
+It returns the comments it skipped. If a block comment is never closed, it is
+returned as the second value instead
+*/
+func (l *Lexer) skipWhitespaceAndComments() ([]token.Comment, *token.Comment) {
+	var comments []token.Comment
+
+	for {
+		l.skipWhitespace()
+
+		if l.ch != '/' || (l.peekChar() != '/' && l.peekChar() != '*') {
+			return comments, nil
+		}
+
+		comment, terminated := l.readComment()
+		if !terminated {
+			return comments, &comment
+		}
+
+		comments = append(comments, comment)
+	}
+}
+
+/*
+Reads a line comment (starting with //) or a block comment (delimited by slash-star and star-slash) and advances the position
+and readPosition pointers in the input string to the end of the comment
+
+It returns the comment and false if a block comment reached the end of the input unclosed
+*/
+func (l *Lexer) readComment() (token.Comment, bool) {
+	comment := token.Comment{Pos: l.currentPosition()}
+	position := l.position
+
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+
+		comment.Text = strings.TrimSuffix(l.input[position:l.position], "\r")
+		return comment, true
+	}
+
+	// skip the opening "/*"
+	l.readChar()
+	l.readChar()
+
+	for l.ch != '*' || l.peekChar() != '/' {
+		if l.ch == 0 {
+			comment.Text = l.input[position:l.position]
+			return comment, false
+		}
+		l.readChar()
+	}
+
+	// skip the closing "*/"
+	l.readChar()
+	l.readChar()
+
+	comment.Text = l.input[position:l.position]
+	return comment, true
+}
+
+/*
+Reads the next character (a UTF-8 encoded rune) in the input and advances the position
 and readPosition pointers in the input string
 */
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
+	l.column++
+}
+
+/* Returns the position of the current character in the input */
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Line: l.line, Column: l.column, Offset: l.position}
 }
 
 /*
 Returns the next character in the input without advancing the
 position and readPosition pointers in the input string
 */
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
 	}
 }
 
@@ -158,18 +356,38 @@ func (l *Lexer) readIdentifier() string {
 Reads the next number in the input and advances the position and
 readPosition pointers in the input string to the end of the number
 
-It returns the number as a string and its type (INT or FLOAT)
+It returns the number as a string and its type (INT or FLOAT). Numbers can be
+decimal with an optional fraction and exponent (1.5e-3), or hexadecimal (0xFF),
+octal (0o17) or binary (0b1010) integers, all of them with '_' digit separators.
+The digits are validated by the parser, which can report precise errors
 
 It can return an ILLEGAL token if the number is malformed (e.g. 12.34.56)
 */
 func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
+		l.readChar()
+		l.readChar()
+
+		for isLetter(l.ch) || isDigit(l.ch) {
+			l.readChar()
+		}
+
+		return l.input[position:l.position], token.INT
+	}
+
 	isFloat := false
 	dotCount := 0
 	illegal := false
 
-	for isDigit(l.ch) || l.ch == '.' {
+	for isDigit(l.ch) || l.ch == '_' || l.ch == '.' {
 		if l.ch == '.' {
+			// two dots start a range operator, e.g. 0..10, so the number ends here
+			if l.peekChar() == '.' {
+				break
+			}
+
 			dotCount++
 			if dotCount > 1 {
 				illegal = true
@@ -180,11 +398,25 @@ func (l *Lexer) readNumber() (string, token.TokenType) {
 		l.readChar()
 	}
 
+	if !illegal && (l.ch == 'e' || l.ch == 'E') {
+		isFloat = true
+		l.readChar()
+
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+
+		for isDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+	}
+
 	for illegal && !isPossibleTerminator(l.ch) {
 		l.readChar()
 	}
 
 	if illegal {
+		l.illegal[position] = "malformed number literal, too many decimal points"
 		return l.input[position:l.position], token.ILLEGAL
 	}
 
@@ -202,7 +434,7 @@ readPosition pointers in the input string to the end of the operator
 If the next character is not char (e.g '='), it returns the single operator token.
 If it is, it returns the compound operator token
 */
-func (l *Lexer) compundableAssignment(char byte, single token.TokenType, compound token.TokenType) token.Token {
+func (l *Lexer) compundableAssignment(char rune, single token.TokenType, compound token.TokenType) token.Token {
 	ch := l.ch
 	if l.peekChar() != char {
 		return newToken(single, ch)
@@ -213,43 +445,217 @@ func (l *Lexer) compundableAssignment(char byte, single token.TokenType, compoun
 	return token.Token{Type: compound, Literal: literal}
 }
 
+/*
+Extends the operator token that ends at the current character with the next character
+
+If the next character is not char, it returns the token unchanged.
+If it is, it returns the longer compound operator token (e.g. << and = make <<=)
+*/
+func (l *Lexer) extendOperator(tok token.Token, char rune, compound token.TokenType) token.Token {
+	if l.peekChar() != char {
+		return tok
+	}
+
+	l.readChar()
+	return token.Token{Type: compound, Literal: tok.Literal + string(l.ch)}
+}
+
 /*
 Reads the next string in the input and advances the position and
 readPosition pointers in the input string to the end of the string's closing ' " '
 
-It returns the string as a string literal
+Escape sequences are decoded, so the returned STRING token's literal is the string's value.
+It returns an ILLEGAL token holding the raw source text if the string is never closed or
+contains an invalid escape sequence
+
+If the string contains an interpolation, only the text before it is read and an INTERP_HEAD
+token is returned, the rest of the string is read once the interpolation is closed
+*/
+func (l *Lexer) readString() token.Token {
+	return l.readStringPart(token.INTERP_HEAD, token.STRING)
+}
+
+/*
+Reads the text of a string from the current character (the opening ' " ' or the '}' that
+closes an interpolation) up to the next interpolation or the end of the string
+
+It returns a token of type open if the text ends at the start of an interpolation ('${'),
+leaving the current character on its '{', or a token of type closed if it ends at the
+closing ' " '
+*/
+func (l *Lexer) readStringPart(open, closed token.TokenType) token.Token {
+	start := l.position
+	reason := ""
+
+	var out strings.Builder
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case 0:
+			l.markUnterminated(start, "unterminated string literal")
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[start:l.position]}
+
+		case '"':
+			if reason != "" {
+				l.illegal[start] = reason
+				return token.Token{Type: token.ILLEGAL, Literal: l.input[start : l.position+1]}
+			}
+
+			return token.Token{Type: closed, Literal: out.String()}
+
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteRune(l.ch)
+				continue
+			}
+
+			l.readChar()
+			l.interpolations = append(l.interpolations, 0)
+
+			if reason != "" {
+				l.illegal[start] = reason
+				return token.Token{Type: token.ILLEGAL, Literal: l.input[start : l.position+1]}
+			}
+
+			return token.Token{Type: open, Literal: out.String()}
+
+		case '\\':
+			l.readChar()
+
+			if l.ch == 0 {
+				l.markUnterminated(start, "unterminated string literal")
+				return token.Token{Type: token.ILLEGAL, Literal: l.input[start:]}
+			}
+
+			r, msg := l.readEscape()
+			if msg != "" && reason == "" {
+				reason = msg
+			}
+
+			out.WriteRune(r)
+
+		default:
+			out.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
+}
+
+/*
+Decodes the escape sequence whose first character (after the backslash) is the current character
+
+It returns the decoded rune, or an error message if the escape sequence is invalid
 */
-func (l *Lexer) readString() string {
-	position := l.position + 1
+func (l *Lexer) readEscape() (rune, string) {
+	switch l.ch {
+	case 'n':
+		return '\n', ""
+	case 't':
+		return '\t', ""
+	case 'r':
+		return '\r', ""
+	case '0':
+		return 0, ""
+	case '"':
+		return '"', ""
+	case '\\':
+		return '\\', ""
+	case '$':
+		return '$', ""
+	case 'u':
+		return l.readUnicodeEscape()
+	default:
+		return unicode.ReplacementChar, fmt.Sprintf("invalid escape sequence \\%c", l.ch)
+	}
+}
+
+/*
+Decodes a unicode escape sequence of the form \u{XXXX}, with 1 to 6 hexadecimal digits,
+leaving the current character on the closing '}'
+*/
+func (l *Lexer) readUnicodeEscape() (rune, string) {
+	if l.peekChar() != '{' {
+		return unicode.ReplacementChar, "invalid unicode escape, expected \\u{...}"
+	}
+	l.readChar()
+
+	start := l.position + 1
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[start : l.position+1]
+
+	if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		return unicode.ReplacementChar, "invalid unicode escape, expected \\u{...} with 1 to 6 hex digits"
+	}
+	l.readChar()
+
+	value, _ := strconv.ParseUint(digits, 16, 32)
+	if value > unicode.MaxRune || (0xD800 <= value && value <= 0xDFFF) {
+		return unicode.ReplacementChar, fmt.Sprintf("invalid unicode code point \\u{%s}", digits)
+	}
+
+	return rune(value), ""
+}
+
+/*
+Reads the next raw string in the input and advances the position and
+readPosition pointers in the input string to the end of the string's closing '`'
+
+Raw strings can span multiple lines and don't process escape sequences.
+It returns an ILLEGAL token holding the raw source text if the string is never closed
+*/
+func (l *Lexer) readRawString() token.Token {
+	start := l.position
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+
+		if l.ch == 0 {
+			l.markUnterminated(start, "unterminated raw string literal")
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[start:l.position]}
+		}
+
+		if l.ch == '`' {
 			break
 		}
 	}
-	return l.input[position:l.position]
+
+	literal := strings.ReplaceAll(l.input[start+1:l.position], "\r", "")
+	return token.Token{Type: token.STRING, Literal: literal}
 }
 
-/* Returns true if the given byte is a letter */
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+/* Returns true if the given rune is a unicode letter or an underscore */
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
-/* Returns true if the given byte is a digit */
-func isDigit(ch byte) bool {
+/* Returns true if the given rune is an ASCII digit */
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+/* Returns true if the given rune follows a leading '0' to mark a non-decimal integer (0x, 0o, 0b) */
+func isBasePrefix(ch rune) bool {
+	return ch == 'x' || ch == 'X' || ch == 'o' || ch == 'O' || ch == 'b' || ch == 'B'
+}
+
+/* Returns true if the given rune is a hexadecimal digit */
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 /* Returns a new token with the given type and literal */
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
 /*
-Returns true if the given byte can be a terminator
+Returns true if the given rune can be a terminator
 
 These are ' ', 0, '\t', '\n', '\r', ';', ')', '}', ']', ','
 */
-func isPossibleTerminator(ch byte) bool {
+func isPossibleTerminator(ch rune) bool {
 	return ch == ' ' || ch == 0 || ch == '\t' || ch == '\n' || ch == '\r' || ch == ';' || ch == ')' || ch == '}' || ch == ']' || ch == ','
 }
@@ -65,7 +65,7 @@ func TestNextToken(t *testing.T) {
 			},
 		},
 		{
-			input: `!-+/*
+			input: `!-+/ *
 			< <= > >= == !=
 			+= -= *= /=
 			% %=`,
@@ -217,6 +217,19 @@ func TestNextToken(t *testing.T) {
 				{token.EOF, ""},
 			},
 		},
+		{
+			input: `0xFF 0o17 0b1010 1_000_000 1.5e-3 2E10 0x_ff`,
+			expected: []ExpectedToken{
+				{token.INT, "0xFF"},
+				{token.INT, "0o17"},
+				{token.INT, "0b1010"},
+				{token.INT, "1_000_000"},
+				{token.FLOAT, "1.5e-3"},
+				{token.FLOAT, "2E10"},
+				{token.INT, "0x_ff"},
+				{token.EOF, ""},
+			},
+		},
 		{
 			input: `while (true) {
 				continue;
@@ -234,12 +247,153 @@ func TestNextToken(t *testing.T) {
 			},
 		},
 		{
-			input: `&& || & |`,
+			input: `&& || & | ^ ~ << >> < > <= >=`,
 			expected: []ExpectedToken{
 				{token.AND, "&&"},
 				{token.OR, "||"},
-				{token.ILLEGAL, "&"},
-				{token.ILLEGAL, "|"},
+				{token.BIT_AND, "&"},
+				{token.BIT_OR, "|"},
+				{token.BIT_XOR, "^"},
+				{token.BIT_NOT, "~"},
+				{token.SHL, "<<"},
+				{token.SHR, ">>"},
+				{token.LT, "<"},
+				{token.GT, ">"},
+				{token.LT_EQ, "<="},
+				{token.GT_EQ, ">="},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `x &= 1; x |= 2; x ^= 3; x <<= 4; x >>= 5;`,
+			expected: []ExpectedToken{
+				{token.IDENT, "x"},
+				{token.BIT_AND_EQ, "&="},
+				{token.INT, "1"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.BIT_OR_EQ, "|="},
+				{token.INT, "2"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.BIT_XOR_EQ, "^="},
+				{token.INT, "3"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.SHL_EQ, "<<="},
+				{token.INT, "4"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.SHR_EQ, ">>="},
+				{token.INT, "5"},
+				{token.SEMICOLON, ";"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `2 ** 3 * 4; x **= 2; x *= 3;`,
+			expected: []ExpectedToken{
+				{token.INT, "2"},
+				{token.POWER, "**"},
+				{token.INT, "3"},
+				{token.ASTERISK, "*"},
+				{token.INT, "4"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.POWER_EQ, "**="},
+				{token.INT, "2"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.ASTERISK_EQ, "*="},
+				{token.INT, "3"},
+				{token.SEMICOLON, ";"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `null ?? x ? y`,
+			expected: []ExpectedToken{
+				{token.NULL, "null"},
+				{token.NULLISH, "??"},
+				{token.IDENT, "x"},
+				{token.ILLEGAL, "?"},
+				{token.IDENT, "y"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `match (x) { _ => x == 1 }`,
+			expected: []ExpectedToken{
+				{token.MATCH, "match"},
+				{token.LPAREN, "("},
+				{token.IDENT, "x"},
+				{token.RPAREN, ")"},
+				{token.LBRACE, "{"},
+				{token.IDENT, "_"},
+				{token.ARROW, "=>"},
+				{token.IDENT, "x"},
+				{token.EQ, "=="},
+				{token.INT, "1"},
+				{token.RBRACE, "}"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `for (i, x in xs)`,
+			expected: []ExpectedToken{
+				{token.FOR, "for"},
+				{token.LPAREN, "("},
+				{token.IDENT, "i"},
+				{token.COMMA, ","},
+				{token.IDENT, "x"},
+				{token.IN, "in"},
+				{token.IDENT, "xs"},
+				{token.RPAREN, ")"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `[a, ...b] .. .5`,
+			expected: []ExpectedToken{
+				{token.LBRACKET, "["},
+				{token.IDENT, "a"},
+				{token.COMMA, ","},
+				{token.ELLIPSIS, "..."},
+				{token.IDENT, "b"},
+				{token.RBRACKET, "]"},
+				{token.RANGE, ".."},
+				{token.FLOAT, ".5"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `xs |> f() || a | b |= c`,
+			expected: []ExpectedToken{
+				{token.IDENT, "xs"},
+				{token.PIPE, "|>"},
+				{token.IDENT, "f"},
+				{token.LPAREN, "("},
+				{token.RPAREN, ")"},
+				{token.OR, "||"},
+				{token.IDENT, "a"},
+				{token.BIT_OR, "|"},
+				{token.IDENT, "b"},
+				{token.BIT_OR_EQ, "|="},
+				{token.IDENT, "c"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `0..10:2 1.5..n`,
+			expected: []ExpectedToken{
+				{token.INT, "0"},
+				{token.RANGE, ".."},
+				{token.INT, "10"},
+				{token.COLON, ":"},
+				{token.INT, "2"},
+				{token.FLOAT, "1.5"},
+				{token.RANGE, ".."},
+				{token.IDENT, "n"},
 				{token.EOF, ""},
 			},
 		},
@@ -276,3 +430,325 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x += 10;\n"
+
+	expected := []token.Position{
+		{Line: 1, Column: 1, Offset: 0},
+		{Line: 1, Column: 5, Offset: 4},
+		{Line: 1, Column: 7, Offset: 6},
+		{Line: 1, Column: 9, Offset: 8},
+		{Line: 1, Column: 10, Offset: 9},
+		{Line: 2, Column: 3, Offset: 13},
+		{Line: 2, Column: 5, Offset: 15},
+		{Line: 2, Column: 8, Offset: 18},
+		{Line: 2, Column: 10, Offset: 20},
+		{Line: 3, Column: 1, Offset: 22},
+	}
+
+	l := New(input)
+
+	for i, pos := range expected {
+		tok := l.NextToken()
+
+		if tok.Pos != pos {
+			t.Fatalf("tests[%d] - position wrong for %q. expected=%+v, got=%+v",
+				i, tok.Literal, pos, tok.Pos)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block
+   comment */ x /= 2;
+x / 2 /* unterminated`
+
+	expected := []ExpectedToken{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_EQ, "/="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.ILLEGAL, "/* unterminated"},
+		{token.EOF, ""},
+	}
+
+	expectedComments := map[int][]string{
+		0: {"// leading comment"},
+		5: {"// trailing comment", "/* block\n   comment */"},
+	}
+
+	l := New(input)
+
+	for i, ts := range expected {
+		tok := l.NextToken()
+
+		if tok.Type != ts.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, ts.expectedType, tok.Type)
+		}
+
+		if tok.Literal != ts.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, ts.expectedLiteral, tok.Literal)
+		}
+
+		comments := expectedComments[i]
+		if len(tok.Comments) != len(comments) {
+			t.Fatalf("tests[%d] - wrong number of comments. expected=%d, got=%d", i, len(comments), len(tok.Comments))
+		}
+
+		for j, text := range comments {
+			if tok.Comments[j].Text != text {
+				t.Fatalf("tests[%d] - comment %d wrong. expected=%q, got=%q", i, j, text, tok.Comments[j].Text)
+			}
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []ExpectedToken
+	}{
+		{
+			input: `"a\nb\tc\\d\"e\r\0"`,
+			expected: []ExpectedToken{
+				{token.STRING, "a\nb\tc\\d\"e\r\x00"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `"\u{48}\u{e9}\u{1F600}"`,
+			expected: []ExpectedToken{
+				{token.STRING, "Hé😀"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: "`raw \\n\nstring`;",
+			expected: []ExpectedToken{
+				{token.STRING, "raw \\n\nstring"},
+				{token.SEMICOLON, ";"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `"bad \q escape"; 1`,
+			expected: []ExpectedToken{
+				{token.ILLEGAL, `"bad \q escape"`},
+				{token.SEMICOLON, ";"},
+				{token.INT, "1"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `let s = "never closed`,
+			expected: []ExpectedToken{
+				{token.LET, "let"},
+				{token.IDENT, "s"},
+				{token.ASSIGN, "="},
+				{token.ILLEGAL, `"never closed`},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `"hi ${name}, ${ {"a": 1}["a"] } \${x} $5"`,
+			expected: []ExpectedToken{
+				{token.INTERP_HEAD, "hi "},
+				{token.IDENT, "name"},
+				{token.INTERP_MID, ", "},
+				{token.LBRACE, "{"},
+				{token.STRING, "a"},
+				{token.COLON, ":"},
+				{token.INT, "1"},
+				{token.RBRACE, "}"},
+				{token.LBRACKET, "["},
+				{token.STRING, "a"},
+				{token.RBRACKET, "]"},
+				{token.INTERP_TAIL, " ${x} $5"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `"a ${"b ${c}"}"`,
+			expected: []ExpectedToken{
+				{token.INTERP_HEAD, "a "},
+				{token.INTERP_HEAD, "b "},
+				{token.IDENT, "c"},
+				{token.INTERP_TAIL, ""},
+				{token.INTERP_TAIL, ""},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: "`never closed",
+			expected: []ExpectedToken{
+				{token.ILLEGAL, "`never closed"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `"\`,
+			expected: []ExpectedToken{
+				{token.ILLEGAL, `"\`},
+				{token.EOF, ""},
+			},
+		},
+		{
+			input: `"${x}\`,
+			expected: []ExpectedToken{
+				{token.INTERP_HEAD, ""},
+				{token.IDENT, "x"},
+				{token.ILLEGAL, `}\`},
+				{token.EOF, ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		for i, ts := range tt.expected {
+			tok := l.NextToken()
+
+			if tok.Type != ts.expectedType {
+				t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+					i, ts.expectedType, tok.Type)
+			}
+
+			if tok.Literal != ts.expectedLiteral {
+				t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+					i, ts.expectedLiteral, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestIllegalReasons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"never closed`, "unterminated string literal"},
+		{`"ends in a backslash\`, "unterminated string literal"},
+		{"`never closed", "unterminated raw string literal"},
+		{`"bad \q"`, "invalid escape sequence \\q"},
+		{`"\u48"`, "invalid unicode escape, expected \\u{...}"},
+		{`"\u{D800}"`, "invalid unicode code point \\u{D800}"},
+		{"/* never closed", "unterminated block comment"},
+		{"1.2.3", "malformed number literal, too many decimal points"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.ILLEGAL {
+			t.Fatalf("tokentype wrong for %q. expected=ILLEGAL, got=%q", tt.input, tok.Type)
+		}
+
+		reason, ok := l.IllegalReason(tok)
+		if !ok {
+			t.Fatalf("no reason recorded for %q", tt.input)
+		}
+
+		if reason != tt.expected {
+			t.Errorf("reason wrong for %q. expected=%q, got=%q", tt.input, tt.expected, reason)
+		}
+	}
+}
+
+func TestUnterminated(t *testing.T) {
+	tests := []struct {
+		input        string
+		unterminated bool
+	}{
+		{`"never closed`, true},
+		{`"a\`, true},
+		{"`never closed", true},
+		{"/* never closed", true},
+		{`"bad \q"`, false},
+		{`@`, false},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if l.Unterminated(tok) != tt.unterminated {
+			t.Errorf("Unterminated wrong for %q. expected=%t, got=%t", tt.input, tt.unterminated, l.Unterminated(tok))
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := `let año = "niño";
+	let señal = añadir(año) € 1;`
+
+	expected := []ExpectedToken{
+		{token.LET, "let"},
+		{token.IDENT, "año"},
+		{token.ASSIGN, "="},
+		{token.STRING, "niño"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENT, "señal"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "añadir"},
+		{token.LPAREN, "("},
+		{token.IDENT, "año"},
+		{token.RPAREN, ")"},
+		{token.ILLEGAL, "€"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, ts := range expected {
+		tok := l.NextToken()
+
+		if tok.Type != ts.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, ts.expectedType, tok.Type)
+		}
+
+		if tok.Literal != ts.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, ts.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnicodePositions(t *testing.T) {
+	input := `let ñu = "ñ"; ñu`
+
+	expected := []token.Position{
+		{Line: 1, Column: 1, Offset: 0},
+		{Line: 1, Column: 5, Offset: 4},
+		{Line: 1, Column: 8, Offset: 8},
+		{Line: 1, Column: 10, Offset: 10},
+		{Line: 1, Column: 13, Offset: 14},
+		{Line: 1, Column: 15, Offset: 16},
+		{Line: 1, Column: 17, Offset: 19},
+	}
+
+	l := New(input)
+
+	for i, pos := range expected {
+		tok := l.NextToken()
+
+		if tok.Pos != pos {
+			t.Fatalf("tests[%d] - position wrong for %q. expected=%+v, got=%+v",
+				i, tok.Literal, pos, tok.Pos)
+		}
+	}
+}
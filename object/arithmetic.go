@@ -0,0 +1,55 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+Raises base to the power of exponent, the integer semantics of the ** operator
+
+Negative exponents are an error since the result would not be an integer, and so is a
+result that does not fit in an int64. Uses exponentiation by squaring
+*/
+func IntegerPow(base, exponent int64) (int64, error) {
+	if exponent < 0 {
+		return 0, fmt.Errorf("negative exponent: %d", exponent)
+	}
+
+	result := int64(1)
+	square := base
+	ok := true
+	for remaining := exponent; remaining > 0; remaining >>= 1 {
+		if remaining&1 == 1 {
+			if result, ok = multiplyInt64(result, square); !ok {
+				break
+			}
+		}
+
+		if remaining > 1 {
+			if square, ok = multiplyInt64(square, square); !ok {
+				break
+			}
+		}
+	}
+
+	if !ok {
+		return 0, fmt.Errorf("integer overflow: %d ** %d", base, exponent)
+	}
+
+	return result, nil
+}
+
+// Multiplies a and b, reporting false when the product overflows an int64
+func multiplyInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+
+	return product, true
+}
@@ -1,17 +1,20 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"unicode/utf8"
+)
 
 var Builtins = []struct {
 	Name    string
 	Builtin *Builtin
 }{
-	{"len", &Builtin{Fn: bLen}},
+	{"len", &Builtin{Fn: bLen, Parameters: []string{"value"}}},
 	{"print", &Builtin{Fn: bPrint}},
-	{"first", &Builtin{Fn: bFirst}},
-	{"last", &Builtin{Fn: bLast}},
-	{"tail", &Builtin{Fn: bTail}},
-	{"push", &Builtin{Fn: bPush}},
+	{"first", &Builtin{Fn: bFirst, Parameters: []string{"array"}}},
+	{"last", &Builtin{Fn: bLast, Parameters: []string{"array"}}},
+	{"tail", &Builtin{Fn: bTail, Parameters: []string{"array"}}},
+	{"push", &Builtin{Fn: bPush, Parameters: []string{"array", "element"}}},
 }
 
 func bLen(args ...Object) Object {
@@ -23,7 +26,9 @@ func bLen(args ...Object) Object {
 	case *Array:
 		return &Integer{Value: int64(len(arg.Elements))}
 	case *String:
-		return &Integer{Value: int64(len(arg.Value))}
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *Range:
+		return &Integer{Value: arg.Len()}
 	default:
 		return newError("argument to `len` not supported, got %s", args[0].Type())
 	}
@@ -42,6 +47,14 @@ func bFirst(args ...Object) Object {
 		return newError("wrong number of arguments. got=%d, want=1", len(args))
 	}
 
+	if rng, ok := args[0].(*Range); ok {
+		if rng.Len() > 0 {
+			return rng.At(0)
+		}
+
+		return nil
+	}
+
 	if args[0].Type() != ARRAY_OBJ {
 		return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
 	}
@@ -59,6 +72,14 @@ func bLast(args ...Object) Object {
 		return newError("wrong number of arguments. got=%d, want=1", len(args))
 	}
 
+	if rng, ok := args[0].(*Range); ok {
+		if length := rng.Len(); length > 0 {
+			return rng.At(length - 1)
+		}
+
+		return nil
+	}
+
 	if args[0].Type() != ARRAY_OBJ {
 		return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
 	}
@@ -77,6 +98,15 @@ func bTail(args ...Object) Object {
 		return newError("wrong number of arguments. got=%d, want=1", len(args))
 	}
 
+	// the tail of a range is still a range, so it stays lazy
+	if rng, ok := args[0].(*Range); ok {
+		if rng.Len() > 0 {
+			return &Range{Start: rng.Start + rng.Step, End: rng.End, Step: rng.Step}
+		}
+
+		return nil
+	}
+
 	if args[0].Type() != ARRAY_OBJ {
 		return newError("argument to `tail` must be ARRAY, got %s", args[0].Type())
 	}
@@ -97,15 +127,24 @@ func bPush(args ...Object) Object {
 		return newError("wrong number of arguments. got=%d, want=2", len(args))
 	}
 
-	if args[0].Type() != ARRAY_OBJ {
+	var elements []Object
+	switch arg := args[0].(type) {
+	case *Array:
+		elements = arg.Elements
+	case *Range:
+		rangeElements, err := arg.Elements()
+		if err != nil {
+			return newError("%s", err)
+		}
+		elements = rangeElements
+	default:
 		return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
 	}
 
-	arr := args[0].(*Array)
-	length := len(arr.Elements)
+	length := len(elements)
 
 	newElements := make([]Object, length+1)
-	copy(newElements, arr.Elements)
+	copy(newElements, elements)
 	newElements[length] = args[1]
 
 	return &Array{Elements: newElements}
@@ -1,14 +1,17 @@
 package object
 
+import "fmt"
+
 type Environment struct {
-	store map[string]Object
-	outer *Environment
-	loop  bool
+	store  map[string]Object
+	consts map[string]bool // names declared with const, which Assign refuses to rebind
+	outer  *Environment
+	loop   bool
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, consts: map[string]bool{}, outer: nil}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
@@ -32,10 +35,33 @@ func (e *Environment) GetNoRecursion(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	delete(e.consts, name)
+	e.store[name] = val
+	return val
+}
+
+// Declares a constant in the environment
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.consts[name] = true
 	e.store[name] = val
 	return val
 }
 
+// Rebinds a declared name in the environment that declared it // fails if it's undeclared or a constant
+func (e *Environment) Assign(name string, val Object) error {
+	_, env, ok := e.Get(name)
+	if !ok {
+		return fmt.Errorf("identifier not found: %s", name)
+	}
+
+	if env.consts[name] {
+		return fmt.Errorf("cannot assign to constant %s", name)
+	}
+
+	env.store[name] = val
+	return nil
+}
+
 func (e *Environment) IsLoop() bool {
 	return e.loop
 }
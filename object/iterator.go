@@ -0,0 +1,129 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+)
+
+// An object that can be iterated over with a for-in loop
+type Iterable interface {
+	Object
+	Iterator() Iterator
+}
+
+// Steps through the elements of an iterable object
+type Iterator interface {
+	Object
+	// Returns the index or key and the value of the next element // ok is false once there are none left
+	Next() (key, value Object, ok bool)
+}
+
+// Returns the value bound by a for-in loop with a single variable: the key for hashes, the value otherwise
+func LoopVariable(it Iterator, key, value Object) Object {
+	if _, ok := it.(*hashIterator); ok {
+		return key
+	}
+
+	return value
+}
+
+type arrayIterator struct {
+	array *Array
+	index int
+}
+
+func (a *Array) Iterator() Iterator { return &arrayIterator{array: a} }
+
+func (it *arrayIterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *arrayIterator) Inspect() string  { return fmt.Sprintf("Iterator[%p]", it) }
+func (it *arrayIterator) Next() (Object, Object, bool) {
+	if it.index >= len(it.array.Elements) {
+		return nil, nil, false
+	}
+
+	it.index++
+	return &Integer{Value: int64(it.index - 1)}, it.array.Elements[it.index-1], true
+}
+
+type stringIterator struct {
+	runes []rune
+	index int
+}
+
+func (s *String) Iterator() Iterator { return &stringIterator{runes: []rune(s.Value)} }
+
+func (it *stringIterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *stringIterator) Inspect() string  { return fmt.Sprintf("Iterator[%p]", it) }
+func (it *stringIterator) Next() (Object, Object, bool) {
+	if it.index >= len(it.runes) {
+		return nil, nil, false
+	}
+
+	it.index++
+	return &Integer{Value: int64(it.index - 1)}, &String{Value: string(it.runes[it.index-1])}, true
+}
+
+type rangeIterator struct {
+	rng   *Range
+	index int64
+}
+
+func (r *Range) Iterator() Iterator { return &rangeIterator{rng: r} }
+
+func (it *rangeIterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *rangeIterator) Inspect() string  { return fmt.Sprintf("Iterator[%p]", it) }
+func (it *rangeIterator) Next() (Object, Object, bool) {
+	if it.index >= it.rng.Len() {
+		return nil, nil, false
+	}
+
+	it.index++
+	return &Integer{Value: it.index - 1}, it.rng.At(it.index - 1), true
+}
+
+// Iterates over the pairs of a hash in the order of their keys, the hash itself is unordered
+type hashIterator struct {
+	pairs []HashPair
+	index int
+}
+
+func (h *Hash) Iterator() Iterator {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return keyLess(pairs[i].Key, pairs[j].Key) })
+
+	return &hashIterator{pairs: pairs}
+}
+
+func (it *hashIterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *hashIterator) Inspect() string  { return fmt.Sprintf("Iterator[%p]", it) }
+func (it *hashIterator) Next() (Object, Object, bool) {
+	if it.index >= len(it.pairs) {
+		return nil, nil, false
+	}
+
+	it.index++
+	pair := it.pairs[it.index-1]
+	return pair.Key, pair.Value, true
+}
+
+// Orders hash keys, integers and strings by value, false before true, and keys of different types by type
+func keyLess(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value < b.(*Integer).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	default:
+		return false
+	}
+}
@@ -0,0 +1,64 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Reports whether value is in container, the right side of the in operator
+
+Arrays are searched for an element equal to value, hashes for a key, strings for a substring and
+ranges for an integer. Elements are compared like == compares them: integers, floats and strings
+by value, null only to itself and anything else by identity
+*/
+func Contains(container, value Object) (bool, error) {
+	switch container := container.(type) {
+	case *Array:
+		for _, element := range container.Elements {
+			if equals(element, value) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	case *Hash:
+		key, ok := value.(Hashable)
+		if !ok {
+			return false, fmt.Errorf("unusable as hash key: %s", value.Type())
+		}
+
+		_, ok = container.Pairs[key.HashKey()]
+		return ok, nil
+	case *String:
+		sub, ok := value.(*String)
+		if !ok {
+			return false, fmt.Errorf("unknown operator: %s in %s", value.Type(), container.Type())
+		}
+
+		return strings.Contains(container.Value, sub.Value), nil
+	case *Range:
+		n, ok := value.(*Integer)
+		return ok && container.Contains(n.Value), nil
+	default:
+		return false, fmt.Errorf("unknown operator: %s in %s", value.Type(), container.Type())
+	}
+}
+
+func equals(left, right Object) bool {
+	switch left := left.(type) {
+	case *Integer:
+		right, ok := right.(*Integer)
+		return ok && left.Value == right.Value
+	case *Float:
+		right, ok := right.(*Float)
+		return ok && left.Value == right.Value
+	case *String:
+		right, ok := right.(*String)
+		return ok && left.Value == right.Value
+	case *Null:
+		return right.Type() == NULL_OBJ
+	default:
+		return left == right
+	}
+}
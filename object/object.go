@@ -6,6 +6,7 @@ import (
 	"cidoka/code"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"strings"
 )
 
@@ -28,6 +29,7 @@ const (
 
 	ARRAY_OBJ = "ARRAY"
 	HASH_OBJ  = "HASH"
+	RANGE_OBJ = "RANGE"
 
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 	CLOSURE_OBJ           = "CLOSURE"
@@ -35,6 +37,7 @@ const (
 	COMPILED_LOOP_OBJ = "COMPILED_LOOP"
 	BREAK_OBJ         = "BREAK"
 	CONTINUE_OBJ      = "CONTINUE"
+	ITERATOR_OBJ      = "ITERATOR"
 )
 
 type HashKey struct {
@@ -111,8 +114,19 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// Returns the text an object contributes to an interpolated string, strings are used as is
+func Stringify(obj Object) string {
+	if obj == nil {
+		return "null"
+	}
+
+	return obj.Inspect()
+}
+
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // default values of the parameters // nil for required ones
+	Rest       *ast.Identifier  // rest parameter // or nil
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -122,22 +136,87 @@ func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			params = append(params, p.String()+" = "+f.Defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	if f.Body != nil {
+		out.WriteString(f.Body.String())
+	}
 	out.WriteString("\n}")
 
 	return out.String()
 }
 
+// Describes how many arguments a function accepts, e.g. "2", "1 to 3" or "at least 1"
+func DescribeArity(required, total int, variadic bool) string {
+	switch {
+	case variadic:
+		return fmt.Sprintf("at least %d", required)
+	case required == total:
+		return fmt.Sprintf("%d", total)
+	default:
+		return fmt.Sprintf("%d to %d", required, total)
+	}
+}
+
+// Matches keyword arguments to the parameters they name and returns all the arguments in parameter order
+// Parameters that weren't passed are left nil, or dropped when they come last
+func MatchKeywordArguments(params []string, required int, positional []Object, names []string, values []Object) ([]Object, error) {
+	args := make([]Object, len(params))
+	copy(args, positional)
+	if len(positional) > len(params) {
+		args = append(args, positional[len(params):]...)
+	}
+
+	for i, name := range names {
+		index := -1
+		for j, param := range params {
+			if param == name {
+				index = j
+				break
+			}
+		}
+
+		if index == -1 {
+			return nil, fmt.Errorf("unknown keyword argument %s", name)
+		}
+
+		if args[index] != nil {
+			return nil, fmt.Errorf("argument %s passed more than once", name)
+		}
+
+		args[index] = values[i]
+	}
+
+	for i := 0; i < required && i < len(params); i++ {
+		if args[i] == nil {
+			return nil, fmt.Errorf("missing argument for parameter %s", params[i])
+		}
+	}
+
+	for len(args) > 0 && args[len(args)-1] == nil {
+		args = args[:len(args)-1]
+	}
+
+	return args, nil
+}
+
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn         BuiltinFunction
+	Parameters []string // names of the parameters, for builtins that accept keyword arguments // or nil
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -170,6 +249,114 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
+// The most integers a range can be turned into an array of, e.g. by push or a spread
+const MaxRangeElements = 1 << 24
+
+// A lazy sequence of integers from Start up to End, which is excluded, spaced by Step
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64 // never zero, negative when counting down
+}
+
+// Returns a range from integer bounds, the step defaults to 1 when it's nil
+func NewRange(start, end, step Object) (*Range, error) {
+	if step == nil {
+		step = &Integer{Value: 1}
+	}
+
+	bounds := []int64{}
+	for _, bound := range []Object{start, end, step} {
+		integer, ok := bound.(*Integer)
+		if !ok {
+			return nil, fmt.Errorf("range bounds must be INTEGER, got %s", bound.Type())
+		}
+
+		bounds = append(bounds, integer.Value)
+	}
+
+	if bounds[2] == 0 {
+		return nil, fmt.Errorf("range step cannot be zero")
+	}
+
+	rng := &Range{Start: bounds[0], End: bounds[1], Step: bounds[2]}
+	if rng.length() > math.MaxInt64 {
+		return nil, fmt.Errorf("range %s is too long, it has more than %d integers", rng.Inspect(), int64(math.MaxInt64))
+	}
+
+	return rng, nil
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("%d..%d", r.Start, r.End)
+	}
+
+	return fmt.Sprintf("%d..%d:%d", r.Start, r.End, r.Step)
+}
+
+// Returns the number of integers in the range, NewRange rejects ranges whose length doesn't fit an int64
+func (r *Range) Len() int64 {
+	return int64(r.length())
+}
+
+// Returns the number of integers in the range, computed unsigned so ranges spanning most of int64 don't overflow
+func (r *Range) length() uint64 {
+	var distance, step uint64
+	switch {
+	case r.Step > 0 && r.Start < r.End:
+		distance, step = uint64(r.End)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && r.Start > r.End:
+		distance, step = uint64(r.Start)-uint64(r.End), -uint64(r.Step)
+	default:
+		return 0
+	}
+
+	length := distance / step
+	if distance%step != 0 {
+		length++
+	}
+
+	return length
+}
+
+// Returns the integer at index i, which must be within 0 and Len()
+func (r *Range) At(i int64) *Integer {
+	return &Integer{Value: r.Start + i*r.Step}
+}
+
+// Reports whether n is one of the integers of the range
+func (r *Range) Contains(n int64) bool {
+	// offsets are computed unsigned like in Len
+	var offset, step uint64
+	switch {
+	case r.Step > 0 && r.Start <= n && n < r.End:
+		offset, step = uint64(n)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && r.End < n && n <= r.Start:
+		offset, step = uint64(r.Start)-uint64(n), -uint64(r.Step)
+	default:
+		return false
+	}
+
+	return offset%step == 0
+}
+
+// Returns the integers of the range as array elements
+// It's an error when the range has more than MaxRangeElements integers, as they wouldn't fit in memory
+func (r *Range) Elements() ([]Object, error) {
+	if r.Len() > MaxRangeElements {
+		return nil, fmt.Errorf("range %s is too long to build an array from, at most %d integers allowed", r.Inspect(), MaxRangeElements)
+	}
+
+	elements := make([]Object, r.Len())
+	for i := range elements {
+		elements[i] = r.At(int64(i))
+	}
+
+	return elements, nil
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
@@ -198,8 +385,12 @@ func (h *Hash) Inspect() string {
 
 type CompiledFunction struct {
 	Instructions  code.Instructions
+	SourceMap     code.SourceMap
 	NumLocals     int
 	NumParameters int
+	NumDefaults   int      // number of trailing parameters with a default value
+	Variadic      bool     // true if extra arguments are collected into a rest parameter
+	Parameters    []string // names of the parameters, used to match keyword arguments
 }
 
 func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
@@ -219,8 +410,10 @@ func (c *Closure) Inspect() string {
 
 type CompiledLoop struct {
 	Instructions code.Instructions
+	SourceMap    code.SourceMap
 	NumLocals    int
 	Free         []FreeVariable
+	ContinuePos  int // position in Instructions where the next iteration starts, the target of continue statements
 }
 
 func (l *CompiledLoop) Type() ObjectType { return COMPILED_LOOP_OBJ }
@@ -228,17 +421,31 @@ func (l *CompiledLoop) Inspect() string {
 	return fmt.Sprintf("CompiledFor[%p]", l)
 }
 
+// Where a variable of an enclosing scope used in a loop body lives
+type FreeVariableKind int
+
+const (
+	LocalVariable          FreeVariableKind = iota // a local of the frame
+	ClosureFreeVariable                            // a free variable of the frame's closure
+	CurrentClosureVariable                         // the frame's closure itself, e.g. in a recursive call
+)
+
 type FreeVariable struct {
 	Index int
-	Scope int
+	Scope int // number of frames below the loop
+	Kind  FreeVariableKind
 }
 
-type Break struct{}
+type Break struct {
+	Label string // label of the loop to break out of // empty for the innermost loop
+}
 
 func (b *Break) Type() ObjectType { return BREAK_OBJ }
 func (b *Break) Inspect() string  { return "break" }
 
-type Continue struct{}
+type Continue struct {
+	Label string // label of the loop to continue // empty for the innermost loop
+}
 
 func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
 func (c *Continue) Inspect() string  { return "continue" }
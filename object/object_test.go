@@ -1,6 +1,8 @@
 package object
 
 import (
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -60,3 +62,235 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestDescribeArity(t *testing.T) {
+	tests := []struct {
+		required int
+		total    int
+		variadic bool
+		expected string
+	}{
+		{0, 0, false, "0"},
+		{2, 2, false, "2"},
+		{1, 3, false, "1 to 3"},
+		{1, 1, true, "at least 1"},
+		{0, 2, true, "at least 0"},
+	}
+
+	for _, tt := range tests {
+		if got := DescribeArity(tt.required, tt.total, tt.variadic); got != tt.expected {
+			t.Errorf("DescribeArity(%d, %d, %t) wrong. want=%q, got=%q", tt.required, tt.total, tt.variadic, tt.expected, got)
+		}
+	}
+}
+
+func TestMatchKeywordArguments(t *testing.T) {
+	one := &Integer{Value: 1}
+	two := &Integer{Value: 2}
+
+	tests := []struct {
+		required    int
+		positional  []Object
+		names       []string
+		values      []Object
+		expected    []Object
+		expectedErr string
+	}{
+		{2, []Object{one}, []string{"b"}, []Object{two}, []Object{one, two}, ""},
+		{1, []Object{}, []string{"c", "a"}, []Object{two, one}, []Object{one, nil, two}, ""},
+		{1, []Object{}, []string{"a"}, []Object{one}, []Object{one}, ""},
+		{1, []Object{}, []string{"d"}, []Object{one}, nil, "unknown keyword argument d"},
+		{1, []Object{one}, []string{"a"}, []Object{two}, nil, "argument a passed more than once"},
+		{2, []Object{}, []string{"a"}, []Object{one}, nil, "missing argument for parameter b"},
+	}
+
+	for _, tt := range tests {
+		args, err := MatchKeywordArguments([]string{"a", "b", "c"}, tt.required, tt.positional, tt.names, tt.values)
+
+		if tt.expectedErr != "" {
+			if err == nil || err.Error() != tt.expectedErr {
+				t.Errorf("wrong error. want=%q, got=%v", tt.expectedErr, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if len(args) != len(tt.expected) {
+			t.Fatalf("wrong number of arguments. want=%d, got=%d", len(tt.expected), len(args))
+		}
+
+		for i, arg := range tt.expected {
+			if args[i] != arg {
+				t.Errorf("wrong argument %d. want=%v, got=%v", i, arg, args[i])
+			}
+		}
+	}
+}
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		rng      *Range
+		expected int64
+	}{
+		{&Range{Start: 0, End: 10, Step: 1}, 10},
+		{&Range{Start: 0, End: 10, Step: 3}, 4},
+		{&Range{Start: 0, End: 9, Step: 3}, 3},
+		{&Range{Start: 10, End: 0, Step: -3}, 4},
+		{&Range{Start: 5, End: 5, Step: 1}, 0},
+		{&Range{Start: 5, End: 1, Step: 1}, 0},
+		{&Range{Start: 1, End: 5, Step: -1}, 0},
+		{&Range{Start: math.MinInt64, End: math.MaxInt64, Step: 4}, 1 << 62},
+	}
+
+	for _, tt := range tests {
+		if tt.rng.Len() != tt.expected {
+			t.Errorf("wrong length for %s. want=%d, got=%d", tt.rng.Inspect(), tt.expected, tt.rng.Len())
+		}
+	}
+}
+
+func TestRangeLimits(t *testing.T) {
+	_, err := NewRange(&Integer{Value: -math.MaxInt64}, &Integer{Value: math.MaxInt64}, nil)
+	expected := "range -9223372036854775807..9223372036854775807 is too long, it has more than 9223372036854775807 integers"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error for a range longer than MaxInt64. want=%q, got=%v", expected, err)
+	}
+
+	// the longest range possible, with MaxInt64 integers
+	rng, err := NewRange(&Integer{Value: -math.MaxInt64}, &Integer{Value: math.MaxInt64}, &Integer{Value: 2})
+	if err != nil {
+		t.Fatalf("unexpected error for the longest range: %s", err)
+	}
+
+	if rng.Len() != 1<<63-1 {
+		t.Errorf("wrong length for %s. want=%d, got=%d", rng.Inspect(), int64(1<<63-1), rng.Len())
+	}
+
+	_, err = (&Range{Start: 0, End: MaxRangeElements + 1, Step: 1}).Elements()
+	expected = "range 0..16777217 is too long to build an array from, at most 16777216 integers allowed"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error for Elements of a long range. want=%q, got=%v", expected, err)
+	}
+
+	elements, err := (&Range{Start: 0, End: 6, Step: 2}).Elements()
+	if err != nil || len(elements) != 3 {
+		t.Errorf("wrong Elements for 0..6:2. got=%v, err=%v", elements, err)
+	}
+}
+
+func TestContains(t *testing.T) {
+	key := &String{Value: "a"}
+	hash := &Hash{Pairs: map[HashKey]HashPair{key.HashKey(): {Key: key, Value: &Null{}}}}
+	array := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "b"}, &Null{}}}
+
+	tests := []struct {
+		container Object
+		value     Object
+		expected  bool
+	}{
+		{array, &Integer{Value: 1}, true},
+		{array, &String{Value: "b"}, true},
+		{array, &Null{}, true},
+		{array, &Float{Value: 1}, false},
+		{hash, &String{Value: "a"}, true},
+		{hash, &String{Value: "b"}, false},
+		{&String{Value: "hello"}, &String{Value: "ell"}, true},
+		{&String{Value: "hello"}, &String{Value: "le"}, false},
+		{&Range{Start: 0, End: 10, Step: 3}, &Integer{Value: 9}, true},
+		{&Range{Start: 0, End: 10, Step: 3}, &Integer{Value: 10}, false},
+		{&Range{Start: 10, End: 0, Step: -2}, &Integer{Value: 10}, true},
+		{&Range{Start: 10, End: 0, Step: -2}, &Integer{Value: 0}, false},
+		{&Range{Start: math.MinInt64, End: math.MaxInt64, Step: 2}, &Integer{Value: math.MaxInt64 - 1}, true},
+		{&Range{Start: 0, End: 10, Step: 1}, &String{Value: "1"}, false},
+	}
+
+	for _, tt := range tests {
+		found, err := Contains(tt.container, tt.value)
+		if err != nil {
+			t.Fatalf("unexpected error for %s in %s: %s", tt.value.Inspect(), tt.container.Inspect(), err)
+		}
+
+		if found != tt.expected {
+			t.Errorf("wrong result for %s in %s. want=%t, got=%t", tt.value.Inspect(), tt.container.Inspect(), tt.expected, found)
+		}
+	}
+}
+
+func TestIntegerPow(t *testing.T) {
+	tests := []struct {
+		base     int64
+		exponent int64
+		expected interface{}
+	}{
+		{2, 10, int64(1024)},
+		{-3, 3, int64(-27)},
+		{7, 0, int64(1)},
+		{0, 0, int64(1)},
+		{0, 5, int64(0)},
+		{-1, math.MaxInt64, int64(-1)},
+		{2, 62, int64(1 << 62)},
+		{-2, 63, int64(math.MinInt64)},
+		{3, 39, int64(4052555153018976267)},
+		{2, 63, "integer overflow: 2 ** 63"},
+		{3, 40, "integer overflow: 3 ** 40"},
+		{2, -1, "negative exponent: -1"},
+	}
+
+	for _, tt := range tests {
+		result, err := IntegerPow(tt.base, tt.exponent)
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			if err != nil {
+				t.Errorf("unexpected error for %d ** %d: %s", tt.base, tt.exponent, err)
+			} else if result != expected {
+				t.Errorf("wrong result for %d ** %d. want=%d, got=%d", tt.base, tt.exponent, expected, result)
+			}
+		case string:
+			if err == nil || err.Error() != expected {
+				t.Errorf("wrong error for %d ** %d. want=%q, got=%v", tt.base, tt.exponent, expected, err)
+			}
+		}
+	}
+}
+
+func TestIterators(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Object{&String{Value: "b"}, &Integer{Value: 2}, &String{Value: "a"}, &Integer{Value: 1}} {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: key}
+	}
+
+	tests := []struct {
+		iterable       Iterable
+		expectedKeys   []string
+		expectedValues []string
+	}{
+		{&Array{Elements: []Object{&Integer{Value: 5}, &String{Value: "x"}}}, []string{"0", "1"}, []string{"5", "x"}},
+		{&String{Value: "hé"}, []string{"0", "1"}, []string{"h", "é"}},
+		{hash, []string{"1", "2", "a", "b"}, []string{"1", "2", "a", "b"}},
+		{&Array{}, []string{}, []string{}},
+		{&Range{Start: 6, End: 0, Step: -2}, []string{"0", "1", "2"}, []string{"6", "4", "2"}},
+	}
+
+	for _, tt := range tests {
+		iterator := tt.iterable.Iterator()
+
+		keys := []string{}
+		values := []string{}
+		for key, value, ok := iterator.Next(); ok; key, value, ok = iterator.Next() {
+			keys = append(keys, key.Inspect())
+			values = append(values, value.Inspect())
+		}
+
+		if strings.Join(keys, " ") != strings.Join(tt.expectedKeys, " ") {
+			t.Errorf("wrong keys for %s. want=%v, got=%v", tt.iterable.Inspect(), tt.expectedKeys, keys)
+		}
+
+		if strings.Join(values, " ") != strings.Join(tt.expectedValues, " ") {
+			t.Errorf("wrong values for %s. want=%v, got=%v", tt.iterable.Inspect(), tt.expectedValues, values)
+		}
+	}
+}
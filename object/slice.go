@@ -0,0 +1,94 @@
+package object
+
+import "fmt"
+
+// Resolves an index into a sequence of length elements, negative indices count back from the end
+// ok is false when the index is out of range
+func ResolveIndex(index, length int64) (int64, bool) {
+	if index < 0 {
+		index += length
+	}
+
+	return index, index >= 0 && index < length
+}
+
+/*
+Returns the part of an array, string or range from start up to end, which is excluded
+
+Missing bounds are nil or null and default to the start and the end of the sequence. Negative
+bounds count back from the end. Bounds past either end are clamped, so they give a shorter or
+empty slice instead of an error. Strings are sliced by characters, and ranges stay lazy
+*/
+func Slice(left, start, end Object) (Object, error) {
+	var length int64
+	var runes []rune
+
+	switch left := left.(type) {
+	case *Array:
+		length = int64(len(left.Elements))
+	case *String:
+		runes = []rune(left.Value)
+		length = int64(len(runes))
+	case *Range:
+		length = left.Len()
+	default:
+		return nil, fmt.Errorf("slice operator not supported: %s", left.Type())
+	}
+
+	from, err := sliceBound(start, 0, length)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := sliceBound(end, length, length)
+	if err != nil {
+		return nil, err
+	}
+
+	if to < from {
+		to = from
+	}
+
+	switch left := left.(type) {
+	case *Array:
+		elements := make([]Object, to-from)
+		copy(elements, left.Elements[from:to])
+		return &Array{Elements: elements}, nil
+	case *String:
+		return &String{Value: string(runes[from:to])}, nil
+	default:
+		rng := left.(*Range)
+		sliced := &Range{Start: rng.Start + from*rng.Step, End: rng.End, Step: rng.Step}
+		if to < length {
+			sliced.End = rng.Start + to*rng.Step
+		}
+
+		return sliced, nil
+	}
+}
+
+// Resolves a slice bound against a length, clamping it to the sequence // missing is used when it's nil or null
+func sliceBound(bound Object, missing, length int64) (int64, error) {
+	if bound == nil || bound.Type() == NULL_OBJ {
+		return missing, nil
+	}
+
+	integer, ok := bound.(*Integer)
+	if !ok {
+		return 0, fmt.Errorf("slice bounds must be INTEGER, got %s", bound.Type())
+	}
+
+	index := integer.Value
+	if index < 0 {
+		index += length
+	}
+
+	switch {
+	case index < 0:
+		return 0, nil
+	case index > length:
+		return length, nil
+	default:
+		return index, nil
+	}
+}
@@ -0,0 +1,37 @@
+package object
+
+import "fmt"
+
+// Returns the elements a spread adds to an array literal or to the arguments of a call
+// Arrays, ranges and strings can be spread, a string spreads its characters
+func SpreadElements(value Object) ([]Object, error) {
+	switch value := value.(type) {
+	case *Array:
+		return value.Elements, nil
+	case *Range:
+		return value.Elements()
+	case *String:
+		elements := []Object{}
+		for _, ch := range value.Value {
+			elements = append(elements, &String{Value: string(ch)})
+		}
+
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("cannot spread %s, expected ARRAY, RANGE or STRING", value.Type())
+	}
+}
+
+// Copies the pairs of a hash spread in a hash literal to hash, replacing the pairs with the same keys
+func SpreadPairs(hash *Hash, value Object) error {
+	spread, ok := value.(*Hash)
+	if !ok {
+		return fmt.Errorf("cannot spread %s into a hash, expected HASH", value.Type())
+	}
+
+	for key, pair := range spread.Pairs {
+		hash.Pairs[key] = pair
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Parses the text of an integer literal and returns its value
+
+The literal can be decimal, or hexadecimal (0x), octal (0o) or binary (0b) when
+prefixed, and can contain '_' separators between digits (and right after the prefix)
+
+It returns an error describing why the literal is malformed or out of range
+*/
+func parseIntegerText(literal string) (int64, error) {
+	base, name, digits := 10, "decimal", literal
+
+	if len(literal) > 1 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			base, name = 16, "hexadecimal"
+		case 'o', 'O':
+			base, name = 8, "octal"
+		case 'b', 'B':
+			base, name = 2, "binary"
+		}
+
+		if base != 10 {
+			digits = literal[2:]
+		}
+	}
+
+	clean, err := removeSeparators(digits, base != 10)
+	if err != nil {
+		return 0, err
+	}
+
+	if clean == "" {
+		return 0, fmt.Errorf("%s literal has no digits", name)
+	}
+
+	for _, ch := range clean {
+		if digitValue(ch) >= base {
+			return 0, fmt.Errorf("invalid digit %q in %s literal", ch, name)
+		}
+	}
+
+	value, err := strconv.ParseInt(clean, base, 64)
+	if err != nil {
+		return 0, errors.New("value out of range")
+	}
+
+	return value, nil
+}
+
+/*
+Parses the text of a float literal and returns its value
+
+The literal is a decimal mantissa with an optional fraction, followed by an optional
+exponent (e.g. 1.5e-3), and can contain '_' separators between digits
+
+It returns an error describing why the literal is malformed or out of range
+*/
+func parseFloatText(literal string) (float64, error) {
+	mantissa, exponent, hasExponent := literal, "", false
+	if idx := strings.IndexAny(literal, "eE"); idx >= 0 {
+		mantissa, exponent, hasExponent = literal[:idx], literal[idx+1:], true
+	}
+
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+
+	cleanWhole, err := removeSeparators(whole, false)
+	if err != nil {
+		return 0, err
+	}
+
+	cleanFraction, err := removeSeparators(fraction, false)
+	if err != nil {
+		return 0, err
+	}
+
+	if cleanWhole == "" && cleanFraction == "" {
+		return 0, errors.New("float literal has no digits")
+	}
+
+	text := cleanWhole + "." + cleanFraction
+
+	if hasExponent {
+		sign := ""
+		if strings.HasPrefix(exponent, "+") || strings.HasPrefix(exponent, "-") {
+			sign, exponent = exponent[:1], exponent[1:]
+		}
+
+		cleanExponent, err := removeSeparators(exponent, false)
+		if err != nil {
+			return 0, err
+		}
+
+		if cleanExponent == "" {
+			return 0, errors.New("exponent has no digits")
+		}
+
+		text += "e" + sign + cleanExponent
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, errors.New("value out of range")
+	}
+
+	return value, nil
+}
+
+/*
+Removes the '_' separators from a run of digits and returns the remaining digits
+
+Every separator must sit between two digits, or at the start of the digits when
+afterPrefix is true (e.g. 0x_FF), otherwise an error is returned
+*/
+func removeSeparators(digits string, afterPrefix bool) (string, error) {
+	if !strings.Contains(digits, "_") {
+		return digits, nil
+	}
+
+	var out strings.Builder
+
+	for i := 0; i < len(digits); i++ {
+		if digits[i] != '_' {
+			out.WriteByte(digits[i])
+			continue
+		}
+
+		validBefore := (i == 0 && afterPrefix) || (i > 0 && digits[i-1] != '_')
+		validAfter := i+1 < len(digits) && digits[i+1] != '_'
+
+		if !validBefore || !validAfter {
+			return "", errors.New("'_' must separate successive digits")
+		}
+	}
+
+	return out.String(), nil
+}
+
+/* Returns the value of a digit in any base up to 36, or 36 if the rune is not a digit */
+func digitValue(ch rune) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'z':
+		return int(ch-'a') + 10
+	case 'A' <= ch && ch <= 'Z':
+		return int(ch-'A') + 10
+	default:
+		return 36
+	}
+}
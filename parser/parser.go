@@ -5,20 +5,27 @@ import (
 	"cidoka/lexer"
 	"cidoka/token"
 	"fmt"
-	"strconv"
 )
 
 const (
 	_ int = iota
 	LOWEST
-	ASSIGN      // =, +=, -=, *=, /=, %=
+	ASSIGN      // =, +=, -=, *=, /=, %=, **=, &=, |=, ^=, <<=, >>=
+	NULLISH     // ??
 	LOGICAL_OR  // ||
 	LOGICAL_AND // &&
 	EQUALS      // ==, !=
-	LESSGREATER // <, >, <=, >=
+	LESSGREATER // <, >, <=, >=, in
+	PIPE        // |>
+	RANGE       // ..
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // <<, >>
 	SUM         // +, -
 	PRODUCT     // *, /, %
-	PREFIX      // -X, !X
+	PREFIX      // -X, !X, ~X
+	POWER       // **
 	CALL        // myFunction(X)
 	INDEX       // array[index], hash[key]
 	POSTFIX     // X++, X--
@@ -40,6 +47,13 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK_EQ: ASSIGN,
 	token.SLASH_EQ:    ASSIGN,
 	token.MODULO_EQ:   ASSIGN,
+	token.POWER_EQ:    ASSIGN,
+	token.BIT_AND_EQ:  ASSIGN,
+	token.BIT_OR_EQ:   ASSIGN,
+	token.BIT_XOR_EQ:  ASSIGN,
+	token.SHL_EQ:      ASSIGN,
+	token.SHR_EQ:      ASSIGN,
+	token.NULLISH:     NULLISH,
 	token.OR:          LOGICAL_OR,
 	token.AND:         LOGICAL_AND,
 	token.EQ:          EQUALS,
@@ -48,26 +62,63 @@ var precedences = map[token.TokenType]int{
 	token.GT:          LESSGREATER,
 	token.LT_EQ:       LESSGREATER,
 	token.GT_EQ:       LESSGREATER,
+	token.IN:          LESSGREATER,
+	token.PIPE:        PIPE,
+	token.RANGE:       RANGE,
+	token.BIT_OR:      BIT_OR,
+	token.BIT_XOR:     BIT_XOR,
+	token.BIT_AND:     BIT_AND,
+	token.SHL:         SHIFT,
+	token.SHR:         SHIFT,
 	token.PLUS:        SUM,
 	token.MINUS:       SUM,
 	token.SLASH:       PRODUCT,
 	token.ASTERISK:    PRODUCT,
 	token.MODULO:      PRODUCT,
+	token.POWER:       POWER,
 	token.LPAREN:      CALL,
 	token.LBRACKET:    INDEX,
 	token.INCREMENT:   POSTFIX,
 	token.DECREMENT:   POSTFIX,
 }
 
+/* Token types that start a statement, the parser resynchronises before them after a syntax error */
+var statementKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.CONST:    true,
+	token.RETURN:   true,
+	token.FOR:      true,
+	token.WHILE:    true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+}
+
 type (
 	prefixParseFn  func() ast.Expression
 	infixParseFn   func(ast.Expression) ast.Expression
 	postfixParseFn func(ast.Expression) ast.Expression
 )
 
+// A syntax error found while parsing
+type ParseError struct {
+	Pos      token.Position    // position the error refers to
+	Expected []token.TokenType // token types that would have been valid, empty if any expression would do
+	Actual   token.Token       // token found instead
+	Message  string            // description of the error
+
+	incomplete bool // the error was caused by the input ending before a construct was closed
+}
+
+/* Returns the error formatted as line:column: message */
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Pos, err.Message)
+}
+
 type Parser struct {
-	lex    *lexer.Lexer // lexer instance
-	errors []string     // parsing errors
+	lex    *lexer.Lexer  // lexer instance
+	errors []*ParseError // parsing errors
+
+	recovering bool // true after a syntax error until the parser resynchronises at the end of the statement
 
 	curToken  token.Token // current token
 	peekToken token.Token // next token
@@ -85,7 +136,7 @@ type Parser struct {
 func New(lex *lexer.Lexer) *Parser {
 	parser := &Parser{
 		lex:    lex,
-		errors: []string{},
+		errors: []*ParseError{},
 	}
 
 	// Prefix parse functions
@@ -95,12 +146,17 @@ func New(lex *lexer.Lexer) *Parser {
 	parser.registerPrefix(token.FLOAT, parser.parseFloatLiteral)
 	parser.registerPrefix(token.TRUE, parser.parseBoolean)
 	parser.registerPrefix(token.FALSE, parser.parseBoolean)
+	parser.registerPrefix(token.NULL, parser.parseNullLiteral)
 	parser.registerPrefix(token.STRING, parser.parseStringLiteral)
+	parser.registerPrefix(token.INTERP_HEAD, parser.parseInterpolatedString)
+	parser.registerPrefix(token.ILLEGAL, parser.parseIllegal)
 
 	parser.registerPrefix(token.BANG, parser.parsePrefixExpression)
 	parser.registerPrefix(token.MINUS, parser.parsePrefixExpression)
+	parser.registerPrefix(token.BIT_NOT, parser.parsePrefixExpression)
 
 	parser.registerPrefix(token.IF, parser.parseIfExpression)
+	parser.registerPrefix(token.MATCH, parser.parseMatchExpression)
 	parser.registerPrefix(token.FUNCTION, parser.parseFunctionLiteral)
 
 	parser.registerPrefix(token.LPAREN, parser.parseGroupedExpression)
@@ -115,12 +171,19 @@ func New(lex *lexer.Lexer) *Parser {
 	parser.registerInfix(token.ASTERISK_EQ, parser.parseAssignExpression)
 	parser.registerInfix(token.SLASH_EQ, parser.parseAssignExpression)
 	parser.registerInfix(token.MODULO_EQ, parser.parseAssignExpression)
+	parser.registerInfix(token.POWER_EQ, parser.parseAssignExpression)
+	parser.registerInfix(token.BIT_AND_EQ, parser.parseAssignExpression)
+	parser.registerInfix(token.BIT_OR_EQ, parser.parseAssignExpression)
+	parser.registerInfix(token.BIT_XOR_EQ, parser.parseAssignExpression)
+	parser.registerInfix(token.SHL_EQ, parser.parseAssignExpression)
+	parser.registerInfix(token.SHR_EQ, parser.parseAssignExpression)
 
 	parser.registerInfix(token.PLUS, parser.parseInfixExpression)
 	parser.registerInfix(token.MINUS, parser.parseInfixExpression)
 	parser.registerInfix(token.SLASH, parser.parseInfixExpression)
 	parser.registerInfix(token.ASTERISK, parser.parseInfixExpression)
 	parser.registerInfix(token.MODULO, parser.parseInfixExpression)
+	parser.registerInfix(token.POWER, parser.parsePowerExpression)
 
 	parser.registerInfix(token.EQ, parser.parseInfixExpression)
 	parser.registerInfix(token.NOT_EQ, parser.parseInfixExpression)
@@ -128,12 +191,23 @@ func New(lex *lexer.Lexer) *Parser {
 	parser.registerInfix(token.LT_EQ, parser.parseInfixExpression)
 	parser.registerInfix(token.GT, parser.parseInfixExpression)
 	parser.registerInfix(token.GT_EQ, parser.parseInfixExpression)
+	parser.registerInfix(token.IN, parser.parseInfixExpression)
+
+	parser.registerInfix(token.BIT_AND, parser.parseInfixExpression)
+	parser.registerInfix(token.BIT_OR, parser.parseInfixExpression)
+	parser.registerInfix(token.BIT_XOR, parser.parseInfixExpression)
+	parser.registerInfix(token.SHL, parser.parseInfixExpression)
+	parser.registerInfix(token.SHR, parser.parseInfixExpression)
+
+	parser.registerInfix(token.RANGE, parser.parseRangeExpression)
+	parser.registerInfix(token.PIPE, parser.parsePipeExpression)
 
 	parser.registerInfix(token.LPAREN, parser.parseCallExpression)
 	parser.registerInfix(token.LBRACKET, parser.parseIndexExpression)
 
 	parser.registerInfix(token.AND, parser.parseInfixExpression)
 	parser.registerInfix(token.OR, parser.parseInfixExpression)
+	parser.registerInfix(token.NULLISH, parser.parseInfixExpression)
 
 	// Postfix parse functions
 	parser.postfixParseFns = make(map[token.TokenType]postfixParseFn)
@@ -184,31 +258,123 @@ func (parser *Parser) expectPeek(t token.TokenType) bool {
 
 /* Returns a slice of the parsing errors as strings */
 func (parser *Parser) Errors() []string {
+	errors := make([]string, len(parser.errors))
+	for i, err := range parser.errors {
+		errors[i] = err.Error()
+	}
+
+	return errors
+}
+
+/* Returns a slice of the parsing errors */
+func (parser *Parser) ParseErrors() []*ParseError {
 	return parser.errors
 }
 
+/*
+Returns true if parsing failed only because the input ended too early, e.g. with an unclosed
+'{', '(', '[' or string, so more input could complete it. Returns false if there are no errors
+or any of them is a syntax error that more input can't fix
+*/
+func (parser *Parser) Incomplete() bool {
+	if len(parser.errors) == 0 {
+		return false
+	}
+
+	for _, err := range parser.errors {
+		if !err.incomplete {
+			return false
+		}
+	}
+
+	return true
+}
+
 /* Appends an error message to the parser's errors list when the peekToken pointer didn't match the expected next token */
 func (parser *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, parser.peekToken.Type)
-	parser.errors = append(parser.errors, msg)
+	parser.addError(parser.peekToken, []token.TokenType{t}, msg)
 }
 
 /* Appends an error message to the parser's errors list when no prefix parse function was found for a token type */
 func (parser *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	parser.errors = append(parser.errors, msg)
+	parser.addError(parser.curToken, nil, msg)
 }
 
 /* Appends an error message to the parser's errors list when the parser couldn't parse a token as an integer */
-func (parser *Parser) integerParseError() {
-	msg := fmt.Sprintf("could not parse %q as integer", parser.curToken.Literal)
-	parser.errors = append(parser.errors, msg)
+func (parser *Parser) integerParseError(err error) {
+	msg := fmt.Sprintf("could not parse %q as integer: %s", parser.curToken.Literal, err)
+	parser.addError(parser.curToken, nil, msg)
 }
 
 /* Appends an error message to the parser's errors list when the parser couldn't parse a token as a float */
-func (parser *Parser) floatParseError() {
-	msg := fmt.Sprintf("could not parse %q as float", parser.curToken.Literal)
-	parser.errors = append(parser.errors, msg)
+func (parser *Parser) floatParseError(err error) {
+	msg := fmt.Sprintf("could not parse %q as float: %s", parser.curToken.Literal, err)
+	parser.addError(parser.curToken, nil, msg)
+}
+
+/* Appends an error message to the parser's errors list describing why the current token is illegal */
+func (parser *Parser) illegalTokenError() {
+	msg, ok := parser.lex.IllegalReason(parser.curToken)
+	if !ok {
+		msg = fmt.Sprintf("illegal token %q", parser.curToken.Literal)
+	}
+
+	parser.addError(parser.curToken, nil, msg)
+}
+
+/*
+Appends a syntax error found at the actual token to the parser's errors list
+
+Once an error is found, the errors that follow in the same statement are usually a consequence
+of it, so they are dropped until the parser resynchronises (see synchronize)
+*/
+func (parser *Parser) addError(actual token.Token, expected []token.TokenType, msg string) {
+	if parser.recovering {
+		return
+	}
+
+	err := &ParseError{Pos: actual.Pos, Expected: expected, Actual: actual, Message: msg}
+	err.incomplete = actual.Type == token.EOF || parser.lex.Unterminated(actual)
+
+	parser.errors = append(parser.errors, err)
+	parser.recovering = true
+}
+
+/*
+Recovers from a syntax error by skipping the rest of the statement that contains it
+
+It leaves curToken on the statement's last token: a ';', or the token before a '}', a statement
+keyword or the end of the input. Braces opened while skipping are skipped along with their
+contents, so the '}' that closes an enclosing block is never skipped
+*/
+func (parser *Parser) synchronize() {
+	depth := 0
+
+	for !parser.peekTokenIs(token.EOF) {
+		switch parser.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				parser.recovering = false
+				return
+			}
+		}
+
+		if depth == 0 && (parser.peekTokenIs(token.RBRACE) || statementKeywords[parser.peekToken.Type]) {
+			break
+		}
+
+		parser.nextToken()
+	}
+
+	parser.recovering = false
 }
 
 // ----------------------------------------------------------------------------
@@ -222,7 +388,9 @@ func (parser *Parser) ParseProgram() *ast.Program {
 
 	for parser.curToken.Type != token.EOF {
 		stmt := parser.parseStatement()
-		if stmt != nil {
+		if parser.recovering {
+			parser.synchronize()
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		parser.nextToken()
@@ -237,8 +405,13 @@ func (parser *Parser) ParseProgram() *ast.Program {
 
 /* Parses a statement and returns the resulting AST node */
 func (parser *Parser) parseStatement() ast.Statement {
+	// an identifier followed by a colon labels a loop, e.g. outer: for (...) { ... }
+	if parser.curTokenIs(token.IDENT) && parser.peekTokenIs(token.COLON) {
+		return parser.parseLabeledStatement()
+	}
+
 	switch parser.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return parser.parseLetStatement()
 	case token.RETURN:
 		return parser.parseReturnStatement()
@@ -250,6 +423,12 @@ func (parser *Parser) parseStatement() ast.Statement {
 		return parser.parseBreakStatement()
 	case token.CONTINUE:
 		return parser.parseContinueStatement()
+	case token.FUNCTION:
+		if parser.peekTokenIs(token.IDENT) {
+			return parser.parseFunctionDeclaration()
+		}
+
+		fallthrough
 	default:
 		expr := parser.parseExpressionStatement()
 		if expr != nil && expr.Expression != nil {
@@ -259,15 +438,23 @@ func (parser *Parser) parseStatement() ast.Statement {
 	return nil
 }
 
-/* Parses a let statement and returns the resulting AST node */
+/* Parses a let or a const statement and returns the resulting AST node */
 func (parser *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: parser.curToken}
 
-	if !parser.expectPeek(token.IDENT) {
-		return nil
-	}
+	if parser.peekTokenIs(token.LBRACKET) || parser.peekTokenIs(token.LBRACE) {
+		parser.nextToken()
 
-	stmt.Name = &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+		if stmt.Pattern = parser.parseDestructuringPattern(); stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !parser.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		stmt.Name = &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+	}
 
 	if !parser.expectPeek(token.ASSIGN) {
 		return nil
@@ -277,7 +464,7 @@ func (parser *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = parser.parseExpression(LOWEST)
 
-	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
 		fl.Name = stmt.Name.Value
 	}
 
@@ -316,7 +503,7 @@ func (parser *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	}
 
 	if assignCounter > 1 {
-		parser.errors = append(parser.errors, "multiple assignments in the same statement are not allowed")
+		parser.addError(stmt.Token, nil, "multiple assignments in the same statement are not allowed")
 		return nil
 	}
 
@@ -354,7 +541,7 @@ func (parser *Parser) parseWhileLoopStatement() ast.Statement {
 	return stmt
 }
 
-/* Parses a for loop statement and returns the resulting AST node */
+/* Parses a for loop statement, either C-style or for-in, and returns the resulting AST node */
 func (parser *Parser) parseForLoopStatement() ast.Statement {
 	stmt := &ast.LoopStatement{Token: parser.curToken}
 
@@ -363,6 +550,11 @@ func (parser *Parser) parseForLoopStatement() ast.Statement {
 	}
 
 	parser.nextToken()
+
+	// for (x in ...) and for (i, x in ...) are for-in loops
+	if parser.curTokenIs(token.IDENT) && (parser.peekTokenIs(token.IN) || parser.peekTokenIs(token.COMMA)) {
+		return parser.parseForInStatement(stmt.Token)
+	}
 	switch parser.curToken.Type {
 	case token.SEMICOLON:
 		stmt.Initializer = nil
@@ -403,10 +595,120 @@ func (parser *Parser) parseForLoopStatement() ast.Statement {
 	return stmt
 }
 
-/* Parses a break statement and returns the resulting AST node */
+/*
+Parses the rest of a for-in loop, starting at its first loop variable, and returns the resulting AST node
+
+The loop has either one variable, e.g. for (x in arr), or two, e.g. for (i, x in arr)
+*/
+func (parser *Parser) parseForInStatement(forToken token.Token) ast.Statement {
+	stmt := &ast.ForInStatement{Token: forToken}
+
+	if stmt.Index, stmt.Element = parser.parseLoopVariables(); stmt.Element == nil {
+		return nil
+	}
+
+	if !parser.expectPeek(token.IN) {
+		return nil
+	}
+
+	parser.nextToken()
+	stmt.Iterable = parser.parseExpression(LOWEST)
+
+	if !parser.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = parser.parseBlockStatement()
+
+	return stmt
+}
+
+/*
+Parses the variables of a for-in loop or a comprehension, starting at the first one
+
+It returns the index variable, nil when there's a single variable, and the element variable, which is
+nil if the variables are malformed
+*/
+func (parser *Parser) parseLoopVariables() (*ast.Identifier, *ast.Identifier) {
+	element := &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+
+	if !parser.peekTokenIs(token.COMMA) {
+		return nil, element
+	}
+
+	parser.nextToken()
+
+	if !parser.expectPeek(token.IDENT) {
+		return nil, nil
+	}
+
+	index := element
+	element = &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+
+	if index.Value == element.Value {
+		msg := fmt.Sprintf("duplicate loop variable %s", element.Value)
+		parser.addError(parser.curToken, nil, msg)
+		return nil, nil
+	}
+
+	return index, element
+}
+
+/*
+Parses a labeled loop statement, e.g. outer: for (...) { ... }, and returns the resulting AST node
+
+Only loops can be labeled, the label names them for the break and continue statements in their body
+*/
+func (parser *Parser) parseLabeledStatement() ast.Statement {
+	label := &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+
+	parser.nextToken()
+	parser.nextToken()
+
+	var stmt ast.Statement
+	switch parser.curToken.Type {
+	case token.FOR:
+		stmt = parser.parseForLoopStatement()
+	case token.WHILE:
+		stmt = parser.parseWhileLoopStatement()
+	default:
+		msg := fmt.Sprintf("expected a loop after label %s, got %s instead", label.Value, parser.curToken.Type)
+		parser.addError(parser.curToken, []token.TokenType{token.FOR, token.WHILE}, msg)
+		return nil
+	}
+
+	switch loop := stmt.(type) {
+	case *ast.LoopStatement:
+		loop.Label = label
+	case *ast.ForInStatement:
+		loop.Label = label
+	}
+
+	return stmt
+}
+
+/*
+Checks if the token after a break or continue is its label, an identifier on the same line
+
+An identifier on the next line starts a new statement instead
+*/
+func (parser *Parser) peekLabel() bool {
+	return parser.peekTokenIs(token.IDENT) && parser.peekToken.Pos.Line == parser.curToken.Pos.Line
+}
+
+/* Parses a break statement, with an optional label, and returns the resulting AST node */
 func (parser *Parser) parseBreakStatement() *ast.BreakStatement {
 	stmt := &ast.BreakStatement{Token: parser.curToken}
 
+	if parser.peekLabel() {
+		parser.nextToken()
+		stmt.Label = &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+	}
+
 	if parser.peekTokenIs(token.SEMICOLON) {
 		parser.nextToken()
 	}
@@ -414,9 +716,15 @@ func (parser *Parser) parseBreakStatement() *ast.BreakStatement {
 	return stmt
 }
 
+/* Parses a continue statement, with an optional label, and returns the resulting AST node */
 func (parser *Parser) parseContinueStatement() *ast.ContinueStatement {
 	stmt := &ast.ContinueStatement{Token: parser.curToken}
 
+	if parser.peekLabel() {
+		parser.nextToken()
+		stmt.Label = &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+	}
+
 	if parser.peekTokenIs(token.SEMICOLON) {
 		parser.nextToken()
 	}
@@ -466,6 +774,19 @@ func (parser *Parser) parseAssignExpression(left ast.Expression) ast.Expression
 		Left:     left,
 	}
 
+	switch left.(type) {
+	case *ast.ArrayLiteral, *ast.HashLiteral:
+		if !parser.curTokenIs(token.ASSIGN) {
+			msg := fmt.Sprintf("destructuring assignment requires =, got %s", parser.curToken.Literal)
+			parser.addError(parser.curToken, nil, msg)
+			return nil
+		}
+
+		if expression.Pattern = parser.assignmentPattern(left, map[string]bool{}); expression.Pattern == nil {
+			return nil
+		}
+	}
+
 	parser.nextToken()
 	expression.Right = parser.parseExpression(ASSIGN)
 
@@ -477,13 +798,19 @@ func (parser *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
 }
 
+/* Reports an illegal token produced by the lexer, there is no AST node for it */
+func (parser *Parser) parseIllegal() ast.Expression {
+	parser.illegalTokenError()
+	return nil
+}
+
 /* Parses an integer literal and returns the resulting AST node */
 func (parser *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: parser.curToken}
 
-	value, err := strconv.ParseInt(parser.curToken.Literal, 0, 64)
+	value, err := parseIntegerText(parser.curToken.Literal)
 	if err != nil {
-		parser.integerParseError()
+		parser.integerParseError(err)
 		return nil
 	}
 
@@ -496,9 +823,9 @@ func (parser *Parser) parseIntegerLiteral() ast.Expression {
 func (parser *Parser) parseFloatLiteral() ast.Expression {
 	lit := &ast.FloatLiteral{Token: parser.curToken}
 
-	value, err := strconv.ParseFloat(parser.curToken.Literal, 64)
+	value, err := parseFloatText(parser.curToken.Literal)
 	if err != nil {
-		parser.floatParseError()
+		parser.floatParseError(err)
 		return nil
 	}
 
@@ -512,11 +839,61 @@ func (parser *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: parser.curToken, Value: parser.curTokenIs(token.TRUE)}
 }
 
+/* Parses the null literal and returns the resulting AST node */
+func (parser *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: parser.curToken}
+}
+
 /* Parses a string literal and returns the resulting AST node */
 func (parser *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: parser.curToken, Value: parser.curToken.Literal}
 }
 
+/*
+Parses an interpolated string and returns the resulting AST node
+
+The string's text parts come from the INTERP_HEAD, INTERP_MID and INTERP_TAIL tokens,
+with an embedded expression between each pair of them. Empty text parts are left out
+*/
+func (parser *Parser) parseInterpolatedString() ast.Expression {
+	interp := &ast.InterpolatedString{Token: parser.curToken}
+	interp.Parts = parser.appendInterpolationText(interp.Parts)
+
+	for !parser.curTokenIs(token.INTERP_TAIL) {
+		if parser.peekTokenIs(token.INTERP_MID) || parser.peekTokenIs(token.INTERP_TAIL) {
+			parser.addError(parser.peekToken, nil, "empty string interpolation")
+			return nil
+		}
+
+		parser.nextToken()
+
+		expression := parser.parseExpression(LOWEST)
+		if expression == nil {
+			return nil
+		}
+		interp.Parts = append(interp.Parts, expression)
+
+		if !parser.peekTokenIs(token.INTERP_MID) && !parser.peekTokenIs(token.INTERP_TAIL) {
+			parser.addError(parser.peekToken, []token.TokenType{token.INTERP_MID, token.INTERP_TAIL}, "unterminated string interpolation, expected }")
+			return nil
+		}
+
+		parser.nextToken()
+		interp.Parts = parser.appendInterpolationText(interp.Parts)
+	}
+
+	return interp
+}
+
+/* Appends the current token's text to the parts of an interpolated string, unless it's empty */
+func (parser *Parser) appendInterpolationText(parts []ast.Expression) []ast.Expression {
+	if parser.curToken.Literal == "" {
+		return parts
+	}
+
+	return append(parts, &ast.StringLiteral{Token: parser.curToken, Value: parser.curToken.Literal})
+}
+
 /* Parses a prefix expression and returns the resulting AST node */
 func (parser *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
@@ -546,6 +923,72 @@ func (parser *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+/*
+Parses an exponentiation expression and returns the resulting AST node
+
+** is right-associative, so the right side is parsed one precedence level lower and
+2 ** 3 ** 2 is 2 ** (3 ** 2). It binds tighter than prefix operators, -2 ** 2 is -(2 ** 2)
+*/
+func (parser *Parser) parsePowerExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    parser.curToken,
+		Operator: parser.curToken.Literal,
+		Left:     left,
+	}
+
+	parser.nextToken()
+	expression.Right = parser.parseExpression(POWER - 1)
+
+	return expression
+}
+
+/*
+Parses a range expression and returns the resulting AST node
+
+The end can be followed by a colon and a step, e.g. 0..10:2. The step binds
+as tightly as the end, so 0..n:k + 1 steps by k + 1
+*/
+func (parser *Parser) parseRangeExpression(left ast.Expression) ast.Expression {
+	expression := &ast.RangeExpression{Token: parser.curToken, Start: left}
+
+	parser.nextToken()
+	expression.End = parser.parseExpression(RANGE)
+
+	if parser.peekTokenIs(token.COLON) {
+		parser.nextToken()
+		parser.nextToken()
+		expression.Step = parser.parseExpression(RANGE)
+	}
+
+	return expression
+}
+
+/*
+Parses a pipeline expression and returns the resulting AST node
+
+There is no pipeline node: x |> f(y) is parsed as the call f(x, y), so the left value becomes the
+first argument of the call on the right. The operator is left associative, so
+xs |> filter(f) |> map(g) is map(filter(xs, f), g). Only calls and indexes bind to the right side,
+so in xs |> len() + 1 the addition applies to the result of the call
+*/
+func (parser *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	parser.nextToken()
+	right := parser.parseExpression(POWER)
+	if right == nil {
+		return nil
+	}
+
+	call, ok := right.(*ast.CallExpression)
+	if !ok {
+		msg := fmt.Sprintf("the right side of |> must be a call, got %s", right.String())
+		parser.addError(token.Token{Literal: right.TokenLiteral(), Pos: right.Pos()}, nil, msg)
+		return nil
+	}
+
+	call.Arguments = append([]ast.Expression{left}, call.Arguments...)
+	return call
+}
+
 /* Parses a postfix expression and returns the resulting AST node */
 func (parser *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.PostfixExpression{
@@ -619,12 +1062,18 @@ func (parser *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	for !parser.curTokenIs(token.RBRACE) && !parser.curTokenIs(token.EOF) {
 		stmt := parser.parseStatement()
-		if stmt != nil {
+		if parser.recovering {
+			parser.synchronize()
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		parser.nextToken()
 	}
 
+	if parser.curTokenIs(token.EOF) {
+		parser.addError(parser.curToken, []token.TokenType{token.RBRACE}, "unexpected end of input, expected } to close the block")
+	}
+
 	return block
 }
 
@@ -632,80 +1081,251 @@ func (parser *Parser) parseBlockStatement() *ast.BlockStatement {
 func (parser *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: parser.curToken}
 
-	if !parser.expectPeek(token.LPAREN) {
+	if !parser.parseFunction(lit) {
 		return nil
 	}
 
-	lit.Parameters = parser.parseFunctionParameters()
+	return lit
+}
 
-	if !parser.expectPeek(token.LBRACE) {
+/* Parses a named function declaration, e.g. fn add(a, b) { a + b }, and returns the resulting AST node */
+func (parser *Parser) parseFunctionDeclaration() *ast.FunctionDeclaration {
+	stmt := &ast.FunctionDeclaration{Token: parser.curToken}
+	lit := &ast.FunctionLiteral{Token: parser.curToken}
+
+	parser.nextToken()
+
+	stmt.Name = &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+	lit.Name = stmt.Name.Value
+
+	if !parser.parseFunction(lit) {
 		return nil
 	}
 
+	stmt.Function = lit
+
+	if parser.peekTokenIs(token.SEMICOLON) {
+		parser.nextToken()
+	}
+
+	return stmt
+}
+
+/* Parses the parameters and the body of a function into the function literal, returning false if they are malformed */
+func (parser *Parser) parseFunction(lit *ast.FunctionLiteral) bool {
+	if !parser.expectPeek(token.LPAREN) {
+		return false
+	}
+
+	if !parser.parseFunctionParameters(lit) {
+		return false
+	}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return false
+	}
+
 	lit.Body = parser.parseBlockStatement()
 
-	return lit
+	return true
 }
 
-/* Parses function parameters and returns a slice of the resulting AST nodes */
-func (parser *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+/*
+Parses function parameters into the function literal, returning false if they are malformed
 
-	if parser.peekTokenIs(token.RPAREN) {
+A parameter can have a default value, e.g. b = 10, after which every parameter needs one. A rest
+parameter, e.g. ...args, can only come last
+*/
+func (parser *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+	lit.Defaults = []ast.Expression{}
+
+	for !parser.peekTokenIs(token.RPAREN) {
 		parser.nextToken()
-		return identifiers
+
+		if parser.curTokenIs(token.ELLIPSIS) {
+			if !parser.expectPeek(token.IDENT) {
+				return false
+			}
+
+			lit.Rest = &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+			break
+		}
+
+		if !parser.curTokenIs(token.IDENT) {
+			msg := fmt.Sprintf("expected a parameter name, got %s", parser.curToken.Type)
+			parser.addError(parser.curToken, []token.TokenType{token.IDENT}, msg)
+			return false
+		}
+
+		ident := &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+
+		var value ast.Expression
+		if parser.peekTokenIs(token.ASSIGN) {
+			parser.nextToken()
+			parser.nextToken()
+
+			if value = parser.parseExpression(ASSIGN); value == nil {
+				return false
+			}
+		} else if n := len(lit.Defaults); n > 0 && lit.Defaults[n-1] != nil {
+			msg := fmt.Sprintf("parameter %s needs a default value, it follows a parameter with one", ident.Value)
+			parser.addError(ident.Token, nil, msg)
+			return false
+		}
+
+		lit.Parameters = append(lit.Parameters, ident)
+		lit.Defaults = append(lit.Defaults, value)
+
+		if !parser.peekTokenIs(token.RPAREN) && !parser.expectPeek(token.COMMA) {
+			return false
+		}
 	}
 
-	parser.nextToken()
+	return parser.expectPeek(token.RPAREN)
+}
 
-	ident := &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
-	identifiers = append(identifiers, ident)
+/* Parses a call expression and returns the resulting AST node */
+func (parser *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: parser.curToken, Function: function}
+	exp.Arguments = []ast.Expression{}
+	exp.Keywords = []*ast.KeywordArgument{}
 
-	for parser.peekTokenIs(token.COMMA) {
+	if parser.peekTokenIs(token.RPAREN) {
 		parser.nextToken()
+		return exp
+	}
+
+	names := map[string]bool{}
+
+	for {
 		parser.nextToken()
 
-		ident := &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		if parser.curTokenIs(token.IDENT) && parser.peekTokenIs(token.COLON) {
+			arg := parser.parseKeywordArgument(names)
+			if arg == nil {
+				return nil
+			}
+
+			exp.Keywords = append(exp.Keywords, arg)
+		} else {
+			if len(exp.Keywords) > 0 {
+				parser.addError(parser.curToken, nil, "positional argument follows a keyword argument")
+				return nil
+			}
+
+			exp.Arguments = append(exp.Arguments, parser.parseElement())
+		}
+
+		if !parser.peekTokenIs(token.COMMA) {
+			break
+		}
+
+		parser.nextToken()
 	}
 
 	if !parser.expectPeek(token.RPAREN) {
 		return nil
 	}
 
-	return identifiers
+	return exp
 }
 
-/* Parses a call expression and returns the resulting AST node */
-func (parser *Parser) parseCallExpression(function ast.Expression) ast.Expression {
-	exp := &ast.CallExpression{Token: parser.curToken, Function: function}
-	exp.Arguments = parser.parseExpressionList(token.RPAREN)
-	return exp
+/*
+Parses a keyword argument of a call expression, e.g. port: 5432, and returns the resulting AST node
+
+Names already used in the call are recorded in names, so the same parameter can't be passed twice
+*/
+func (parser *Parser) parseKeywordArgument(names map[string]bool) *ast.KeywordArgument {
+	arg := &ast.KeywordArgument{Token: parser.curToken}
+	arg.Name = &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+
+	if names[arg.Name.Value] {
+		msg := fmt.Sprintf("duplicate keyword argument %s", arg.Name.Value)
+		parser.addError(parser.curToken, nil, msg)
+		return nil
+	}
+	names[arg.Name.Value] = true
+
+	parser.nextToken()
+	parser.nextToken()
+	arg.Value = parser.parseExpression(LOWEST)
+
+	return arg
 }
 
-/* Parses an array literal and returns the resulting AST node */
+/* Parses an array literal, or an array comprehension if its first element is followed by for, and returns the resulting AST node */
 func (parser *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: parser.curToken}
-	array.Elements = parser.parseExpressionList(token.RBRACKET)
+
+	if parser.peekTokenIs(token.RBRACKET) {
+		parser.nextToken()
+		array.Elements = []ast.Expression{}
+		return array
+	}
+
+	parser.nextToken()
+	first := parser.parseElement()
+
+	if parser.peekTokenIs(token.FOR) {
+		comprehension := &ast.ArrayComprehension{Token: array.Token, Element: first}
+		if comprehension.Clause = parser.parseComprehensionClause(token.RBRACKET); comprehension.Clause == nil {
+			return nil
+		}
+
+		return comprehension
+	}
+
+	array.Elements = parser.parseExpressionList(first, token.RBRACKET)
 	return array
 }
 
-/* Parses a list of expressions and returns a slice of the resulting AST nodes */
-func (parser *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
-	list := []ast.Expression{}
+/*
+Parses the for clause of a comprehension and its optional if condition, up to the end token closing the comprehension
 
-	if parser.peekTokenIs(end) {
-		parser.nextToken()
-		return list
+The clause has one or two loop variables like a for-in loop, without the parentheses, e.g.
+for x in xs if x > 0 or for k, v in h
+*/
+func (parser *Parser) parseComprehensionClause(end token.TokenType) *ast.ComprehensionClause {
+	parser.nextToken()
+	clause := &ast.ComprehensionClause{Token: parser.curToken}
+
+	if !parser.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	if clause.Index, clause.Element = parser.parseLoopVariables(); clause.Element == nil {
+		return nil
+	}
+
+	if !parser.expectPeek(token.IN) {
+		return nil
 	}
 
 	parser.nextToken()
-	list = append(list, parser.parseExpression(LOWEST))
+	clause.Iterable = parser.parseExpression(LOWEST)
+
+	if parser.peekTokenIs(token.IF) {
+		parser.nextToken()
+		parser.nextToken()
+		clause.Condition = parser.parseExpression(LOWEST)
+	}
+
+	if !parser.expectPeek(end) {
+		return nil
+	}
+
+	return clause
+}
+
+/* Parses the rest of a list of expressions after its first one and returns a slice of the resulting AST nodes */
+func (parser *Parser) parseExpressionList(first ast.Expression, end token.TokenType) []ast.Expression {
+	list := []ast.Expression{first}
 
 	for parser.peekTokenIs(token.COMMA) {
 		parser.nextToken()
 		parser.nextToken()
-		list = append(list, parser.parseExpression(LOWEST))
+		list = append(list, parser.parseElement())
 	}
 
 	if !parser.expectPeek(end) {
@@ -715,12 +1335,42 @@ func (parser *Parser) parseExpressionList(end token.TokenType) []ast.Expression
 	return list
 }
 
-/* Parses an index expression and returns the resulting AST node */
+/*
+Parses an index or a slice expression and returns the resulting AST node
+
+A colon inside the brackets makes it a slice, e.g. array[1:3], and either of
+its bounds can be left out, e.g. array[:3] or array[1:]
+*/
 func (parser *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: parser.curToken, Left: left}
+	bracket := parser.curToken
+
+	var index ast.Expression
+	if !parser.peekTokenIs(token.COLON) {
+		parser.nextToken()
+		index = parser.parseExpression(LOWEST)
+	}
+
+	if parser.peekTokenIs(token.COLON) {
+		return parser.parseSliceExpression(bracket, left, index)
+	}
+
+	if !parser.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return &ast.IndexExpression{Token: bracket, Left: left, Index: index}
+}
+
+/* Parses the rest of a slice expression after its start, with the current token before the colon */
+func (parser *Parser) parseSliceExpression(bracket token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: bracket, Left: left, Start: start}
 
 	parser.nextToken()
-	exp.Index = parser.parseExpression(LOWEST)
+
+	if !parser.peekTokenIs(token.RBRACKET) {
+		parser.nextToken()
+		exp.End = parser.parseExpression(LOWEST)
+	}
 
 	if !parser.expectPeek(token.RBRACKET) {
 		return nil
@@ -729,13 +1379,54 @@ func (parser *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
-/* Parses a hash literal and returns the resulting AST node */
+/* Parses an element of an array literal or an argument of a call, either of which can be a spread */
+func (parser *Parser) parseElement() ast.Expression {
+	if parser.curTokenIs(token.ELLIPSIS) {
+		if spread := parser.parseSpreadExpression(); spread != nil {
+			return spread
+		}
+
+		return nil
+	}
+
+	return parser.parseExpression(LOWEST)
+}
+
+/* Parses a spread, e.g. ...xs, and returns the resulting AST node */
+func (parser *Parser) parseSpreadExpression() *ast.SpreadExpression {
+	spread := &ast.SpreadExpression{Token: parser.curToken}
+
+	parser.nextToken()
+	if spread.Value = parser.parseExpression(LOWEST); spread.Value == nil {
+		return nil
+	}
+
+	return spread
+}
+
+/* Parses a hash literal, or a hash comprehension if its first pair is followed by for, and returns the resulting AST node */
 func (parser *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: parser.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
 	for !parser.peekTokenIs(token.RBRACE) {
 		parser.nextToken()
+
+		if parser.curTokenIs(token.ELLIPSIS) {
+			spread := parser.parseSpreadExpression()
+			if spread == nil {
+				return nil
+			}
+
+			hash.Spreads = append(hash.Spreads, spread)
+
+			if !parser.peekTokenIs(token.RBRACE) && !parser.expectPeek(token.COMMA) {
+				return nil
+			}
+
+			continue
+		}
+
 		key := parser.parseExpression(LOWEST)
 
 		if !parser.expectPeek(token.COLON) {
@@ -745,6 +1436,16 @@ func (parser *Parser) parseHashLiteral() ast.Expression {
 		parser.nextToken()
 		value := parser.parseExpression(LOWEST)
 
+		// a first pair followed by for makes a hash comprehension
+		if len(hash.Pairs) == 0 && len(hash.Spreads) == 0 && parser.peekTokenIs(token.FOR) {
+			comprehension := &ast.HashComprehension{Token: hash.Token, Key: key, Value: value}
+			if comprehension.Clause = parser.parseComprehensionClause(token.RBRACE); comprehension.Clause == nil {
+				return nil
+			}
+
+			return comprehension
+		}
+
 		hash.Pairs[key] = value
 
 		if !parser.peekTokenIs(token.RBRACE) && !parser.expectPeek(token.COMMA) {
@@ -3,6 +3,7 @@ package parser
 import (
 	"cidoka/ast"
 	"cidoka/lexer"
+	"cidoka/token"
 	"testing"
 )
 
@@ -39,6 +40,37 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+	}{
+		{"const x = 5;", "const x = 5;"},
+		{"const [a, b] = pair", "const [a, b] = pair;"},
+		{"const f = fn(x) { x }", "const f = fn<f>(x) { x };"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+		}
+
+		if !stmt.IsConst() {
+			t.Errorf("stmt.IsConst() is false for %q", tt.input)
+		}
+
+		if stmt.String() != tt.expectedString {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -141,6 +173,8 @@ func TestParsingPrefixExpressions(t *testing.T) {
 		{"-foobar;", "-", "foobar"},
 		{"!true;", "!", true},
 		{"!false;", "!", false},
+		{"~5;", "~", 5},
+		{"~foobar;", "~", "foobar"},
 	}
 
 	for _, tt := range prefixTests {
@@ -197,6 +231,12 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
+		{"foobar ?? barfoo;", "foobar", "??", "barfoo"},
 	}
 
 	for _, tt := range infixTests {
@@ -230,6 +270,82 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"-a * b",
 			"((-a) * b)",
 		},
+		{
+			"a | b ^ c & d << e + f",
+			"(a | (b ^ (c & (d << (e + f)))))",
+		},
+		{
+			"a & 1 == 0",
+			"((a & 1) == 0)",
+		},
+		{
+			"a ?? b || c == null",
+			"(a ?? (b || (c == null)))",
+		},
+		{
+			"x = a ?? b",
+			"x = (a ?? b)",
+		},
+		{
+			"~a & b",
+			"((~a) & b)",
+		},
+		{
+			"a << 1 < b >> 1",
+			"((a << 1) < (b >> 1))",
+		},
+		{
+			"a * b ** c ** d",
+			"(a * (b ** (c ** d)))",
+		},
+		{
+			"-a ** -b + c",
+			"((-(a ** (-b))) + c)",
+		},
+		{
+			"a[0] ** f(b)",
+			"((a[0]) ** f(b))",
+		},
+		{
+			"0..n + 1:-k < c",
+			"((0..(n + 1):(-k)) < c)",
+		},
+		{
+			"x = a | b..c",
+			"x = ((a | b)..c)",
+		},
+		{
+			"a + 1 in xs == !(b in h)",
+			"(((a + 1) in xs) == (!(b in h)))",
+		},
+		{
+			"x in 0..n",
+			"(x in (0..n))",
+		},
+		{
+			"xs |> filter(f) |> map(g, h)",
+			"map(filter(xs, f), g, h)",
+		},
+		{
+			"a + b |> f(c * d) < 0..n |> len()",
+			"(f((a + b), (c * d)) < len((0..n)))",
+		},
+		{
+			"x = a |> f(k: 1) == b",
+			"x = (f(a, k: 1) == b)",
+		},
+		{
+			"x |> f() + 1",
+			"(f(x) + 1)",
+		},
+		{
+			"x |> f() ** 2 * y",
+			"((f(x) ** 2) * y)",
+		},
+		{
+			"x |> fs[0](y) - 1 |> g()",
+			"g(((fs[0])(x, y) - 1))",
+		},
 		{
 			"!-a",
 			"(!(-a))",
@@ -656,6 +772,197 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestForInStatement(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedIndex   string
+		expectedElement string
+		expectedString  string
+	}{
+		{"for (x in xs) { x }", "", "x", "for (x in xs) { { x } }"},
+		{"for (i, x in [1, 2]) { i }", "i", "x", "for (i, x in [1, 2]) { { i } }"},
+		{"for (k, v in h) { }", "k", "v", "for (k, v in h) { {  } }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		loop, ok := program.Statements[0].(*ast.ForInStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForInStatement. got=%T", program.Statements[0])
+		}
+
+		if tt.expectedIndex == "" {
+			if loop.Index != nil {
+				t.Errorf("loop.Index is not nil. got=%s", loop.Index)
+			}
+		} else {
+			testLiteralExpression(t, loop.Index, tt.expectedIndex)
+		}
+
+		testLiteralExpression(t, loop.Element, tt.expectedElement)
+
+		if loop.String() != tt.expectedString {
+			t.Errorf("loop.String() wrong. want=%q, got=%q", tt.expectedString, loop.String())
+		}
+	}
+}
+
+func TestLabeledLoops(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedLabel  string
+		expectedString string
+	}{
+		{"outer: for (x in xs) { break outer; }", "outer", "outer: for (x in xs) { { break outer } }"},
+		{"rows: while (true) { continue rows }", "rows", "rows: for (; true; ) { { continue rows } }"},
+		{"l: for (let i = 0; i < 3; i++) { break }", "l", "l: for (let i = 0; (i < 3); (i++)) { { break } }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var label *ast.Identifier
+		switch loop := program.Statements[0].(type) {
+		case *ast.LoopStatement:
+			label = loop.Label
+		case *ast.ForInStatement:
+			label = loop.Label
+		default:
+			t.Fatalf("program.Statements[0] is not a loop. got=%T", program.Statements[0])
+		}
+
+		testLiteralExpression(t, label, tt.expectedLabel)
+
+		if program.String() != tt.expectedString {
+			t.Errorf("program.String() wrong. want=%q, got=%q", tt.expectedString, program.String())
+		}
+	}
+}
+
+func TestFunctionDeclarationParsing(t *testing.T) {
+	input := `fn add(x, y = 1) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	decl, ok := program.Statements[0].(*ast.FunctionDeclaration)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.FunctionDeclaration. got=%T", program.Statements[0])
+	}
+
+	testLiteralExpression(t, decl.Name, "add")
+
+	if decl.Function.Name != "add" {
+		t.Errorf("decl.Function.Name wrong. want=%q, got=%q", "add", decl.Function.Name)
+	}
+
+	if len(decl.Function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(decl.Function.Parameters))
+	}
+
+	expected := "fn add(x, y = 1) { (x + y) }"
+	if decl.String() != expected {
+		t.Errorf("decl.String() wrong. want=%q, got=%q", expected, decl.String())
+	}
+}
+
+func TestCallExpressionKeywordArguments(t *testing.T) {
+	input := `connect(host, port: 5432, timeout: 10 * 3);`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if len(exp.Arguments) != 1 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], "host")
+
+	if len(exp.Keywords) != 2 {
+		t.Fatalf("wrong length of keyword arguments. got=%d", len(exp.Keywords))
+	}
+
+	testLiteralExpression(t, exp.Keywords[0].Name, "port")
+	testLiteralExpression(t, exp.Keywords[0].Value, 5432)
+	testLiteralExpression(t, exp.Keywords[1].Name, "timeout")
+	testInfixExpression(t, exp.Keywords[1].Value, 10, "*", 3)
+
+	expected := "connect(host, port: 5432, timeout: (10 * 3))"
+	if exp.String() != expected {
+		t.Errorf("exp.String() wrong. want=%q, got=%q", expected, exp.String())
+	}
+}
+
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedString   string
+		expectedDefaults []bool
+		expectedRest     string
+	}{
+		{"fn(a, b = 2) { a }", "fn(a, b = 2) { a }", []bool{false, true}, ""},
+		{"fn(a = 1, b = a * 2) { a }", "fn(a = 1, b = (a * 2)) { a }", []bool{true, true}, ""},
+		{"fn(...rest) { rest }", "fn(...rest) { rest }", []bool{}, "rest"},
+		{"fn(a, b = 1, ...rest) { a }", "fn(a, b = 1, ...rest) { a }", []bool{false, true}, "rest"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if function.String() != tt.expectedString {
+			t.Errorf("function.String() wrong. want=%q, got=%q", tt.expectedString, function.String())
+		}
+
+		if len(function.Defaults) != len(tt.expectedDefaults) {
+			t.Fatalf("length defaults wrong. want=%d, got=%d", len(tt.expectedDefaults), len(function.Defaults))
+		}
+
+		for i, hasDefault := range tt.expectedDefaults {
+			if (function.Defaults[i] != nil) != hasDefault {
+				t.Errorf("default of parameter %d wrong. want=%t, got=%v", i, hasDefault, function.Defaults[i])
+			}
+		}
+
+		if tt.expectedRest == "" {
+			if function.Rest != nil {
+				t.Errorf("function.Rest is not nil. got=%s", function.Rest)
+			}
+			continue
+		}
+
+		if function.Rest == nil || function.Rest.Value != tt.expectedRest {
+			t.Errorf("function.Rest wrong. want=%s, got=%v", tt.expectedRest, function.Rest)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -825,6 +1132,45 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"myArray[1:3]", "(myArray[1:3])"},
+		{"myArray[:-1]", "(myArray[:(-1)])"},
+		{"myArray[i + 1:]", "(myArray[(i + 1):])"},
+		{"myArray[:]", "(myArray[:])"},
+		{"s[1:][0]", "((s[1:])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("wrong slice expression. want=%q, got=%q", tt.expected, stmt.Expression.String())
+		}
+	}
+
+	l := lexer.New("myArray[1:2]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	slice, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+
+	testIdentifier(t, slice.Left, "myArray")
+	testIntegerLiteral(t, slice.Start, 1)
+	testIntegerLiteral(t, slice.End, 2)
+}
+
 func TestParsingEmptyHashLiteral(t *testing.T) {
 	input := "{}"
 
@@ -844,6 +1190,56 @@ func TestParsingEmptyHashLiteral(t *testing.T) {
 	}
 }
 
+func TestParsingSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[...a, ...b, 3]", "[...a, ...b, 3]"},
+		{"[...xs |> tail()]", "[...tail(xs)]"},
+		{"f(1, ...args, k: 2)", "f(1, ...args, k: 2)"},
+		{`{...defaults, "port": 80}`, "{...defaults, port:80}"},
+		{`{"port": 80, ...defaults}`, "{port:80, ...defaults}"},
+		{"[first, ...rest] = xs", "[first, ...rest] = xs"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestParsingComprehensions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[x * 2 for x in xs]", "[(x * 2) for x in xs]"},
+		{"[x for i, x in xs if i % 2 == 0]", "[x for i, x in xs if ((i % 2) == 0)]"},
+		{"[...row for row in rows]", "[...row for row in rows]"},
+		{"[[x, y] for x in 1..3 if x in ys]", "[[x, y] for x in (1..3) if (x in ys)]"},
+		{"{k: v * v for k, v in h}", "{k:(v * v) for k, v in h}"},
+		{"{x: true for x in xs |> tail()}", "{x:true for x in tail(xs)}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -1055,6 +1451,263 @@ func TestParsingFloatLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestNullLiteral(t *testing.T) {
+	l := lexer.New("null;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	null, ok := stmt.Expression.(*ast.NullLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.NullLiteral. got=%T", stmt.Expression)
+	}
+
+	if null.TokenLiteral() != "null" {
+		t.Errorf("null.TokenLiteral not %s. got=%s", "null", null.TokenLiteral())
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (x) {
+		1 => "one",
+		-2.5 => "negative",
+		[a, [b, _]] if a > b => { a },
+		{"type": t, 1: null} => t,
+		_ => null,
+	}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, match.Subject, "x") {
+		return
+	}
+
+	expectedPatterns := []string{"1", "(-2.5)", "[a, [b, _]]", "{type:t, 1:null}", "_"}
+	if len(match.Arms) != len(expectedPatterns) {
+		t.Fatalf("match.Arms does not contain %d arms. got=%d", len(expectedPatterns), len(match.Arms))
+	}
+
+	for i, arm := range match.Arms {
+		if arm.Pattern.String() != expectedPatterns[i] {
+			t.Errorf("arm %d has wrong pattern. expected=%q, got=%q", i, expectedPatterns[i], arm.Pattern.String())
+		}
+
+		if len(arm.Body.Statements) != 1 {
+			t.Errorf("arm %d body does not contain 1 statement. got=%d", i, len(arm.Body.Statements))
+		}
+
+		if (arm.Guard != nil) != (i == 2) {
+			t.Errorf("arm %d has wrong guard. got=%v", i, arm.Guard)
+		}
+	}
+
+	if _, ok := match.Arms[0].Pattern.(*ast.LiteralPattern); !ok {
+		t.Errorf("arm 0 pattern not *ast.LiteralPattern. got=%T", match.Arms[0].Pattern)
+	}
+
+	array, ok := match.Arms[2].Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("arm 2 pattern not *ast.ArrayPattern. got=%T", match.Arms[2].Pattern)
+	}
+
+	if _, ok := array.Elements[0].(*ast.BindingPattern); !ok {
+		t.Errorf("array.Elements[0] not *ast.BindingPattern. got=%T", array.Elements[0])
+	}
+
+	if !testInfixExpression(t, match.Arms[2].Guard, "a", ">", "b") {
+		return
+	}
+
+	hash, ok := match.Arms[3].Pattern.(*ast.HashPattern)
+	if !ok {
+		t.Fatalf("arm 3 pattern not *ast.HashPattern. got=%T", match.Arms[3].Pattern)
+	}
+
+	if len(hash.Keys) != 2 || len(hash.Values) != 2 {
+		t.Errorf("hash pattern does not contain 2 pairs. got=%d", len(hash.Keys))
+	}
+
+	if _, ok := match.Arms[4].Pattern.(*ast.WildcardPattern); !ok {
+		t.Errorf("arm 4 pattern not *ast.WildcardPattern. got=%T", match.Arms[4].Pattern)
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b, ...rest] = x;", "let [a, b, ...rest] = x;"},
+		{"let {name, age} = person;", "let {name:name, age:age} = person;"},
+		{`let {"point": [x, _]} = shape;`, "let {point:[x, _]} = shape;"},
+		{"[a, b] = [b, a];", "[a, b] = [b, a]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New("let [a, ...b] = x; [c] = y;")).ParseProgram()
+
+	let := program.Statements[0].(*ast.LetStatement)
+	pattern, ok := let.Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("let.Pattern not *ast.ArrayPattern. got=%T", let.Pattern)
+	}
+
+	if _, ok := pattern.Rest.(*ast.BindingPattern); !ok {
+		t.Errorf("pattern.Rest not *ast.BindingPattern. got=%T", pattern.Rest)
+	}
+
+	assign := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.AssignExpression)
+	if _, ok := assign.Pattern.(*ast.ArrayPattern); !ok {
+		t.Errorf("assign.Pattern not *ast.ArrayPattern. got=%T", assign.Pattern)
+	}
+}
+
+func TestNumericLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"0xFF", 255},
+		{"0Xff", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_FF_FF", 65535},
+		{"017", 17},
+		{"1.5e-3", 1.5e-3},
+		{"2E10", 2e10},
+		{"1_000.000_5", 1000.0005},
+		{"6.02e+2_3", 6.02e23},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+			if !ok {
+				t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+			}
+			if literal.Value != int64(expected) {
+				t.Errorf("literal.Value not %d. got=%d", expected, literal.Value)
+			}
+		case float64:
+			literal, ok := stmt.Expression.(*ast.FloatLiteral)
+			if !ok {
+				t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+			}
+			if literal.Value != expected {
+				t.Errorf("literal.Value not %g. got=%g", expected, literal.Value)
+			}
+		}
+	}
+}
+
+func TestNumericLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x", "1:1: could not parse \"0x\" as integer: hexadecimal literal has no digits"},
+		{"0b102", "1:1: could not parse \"0b102\" as integer: invalid digit '2' in binary literal"},
+		{"0o8", "1:1: could not parse \"0o8\" as integer: invalid digit '8' in octal literal"},
+		{"0xFG", "1:1: could not parse \"0xFG\" as integer: invalid digit 'G' in hexadecimal literal"},
+		{"1__000", "1:1: could not parse \"1__000\" as integer: '_' must separate successive digits"},
+		{"1_000_", "1:1: could not parse \"1_000_\" as integer: '_' must separate successive digits"},
+		{"9223372036854775808", "1:1: could not parse \"9223372036854775808\" as integer: value out of range"},
+		{"1.5e", "1:1: could not parse \"1.5e\" as float: exponent has no digits"},
+		{"1.5e+", "1:1: could not parse \"1.5e+\" as float: exponent has no digits"},
+		{"1_.5", "1:1: could not parse \"1_.5\" as float: '_' must separate successive digits"},
+		{"1e999", "1:1: could not parse \"1e999\" as float: value out of range"},
+		{"1.2.3", "1:1: malformed number literal, too many decimal points"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	input := `"hello ${name}, you have ${len(items)} items"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	interp, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(interp.Parts) != 5 {
+		t.Fatalf("interp.Parts has wrong length. expected=5, got=%d", len(interp.Parts))
+	}
+
+	texts := map[int]string{0: "hello ", 2: ", you have ", 4: " items"}
+	for i, expected := range texts {
+		text, ok := interp.Parts[i].(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("interp.Parts[%d] not *ast.StringLiteral. got=%T", i, interp.Parts[i])
+		}
+		if text.Value != expected {
+			t.Errorf("interp.Parts[%d] has wrong value. expected=%q, got=%q", i, expected, text.Value)
+		}
+	}
+
+	testIdentifier(t, interp.Parts[1], "name")
+
+	if interp.Parts[3].String() != "len(items)" {
+		t.Errorf("interp.Parts[3] wrong. expected=%q, got=%q", "len(items)", interp.Parts[3].String())
+	}
+
+	if interp.String() != input {
+		t.Errorf("interp.String() wrong. expected=%q, got=%q", input, interp.String())
+	}
+}
+
 func TestAssignStatement(t *testing.T) {
 	input := `
 	let x = 5;
@@ -1217,6 +1870,13 @@ func TestCompoundAssignmentOperators(t *testing.T) {
 		{"x -= 5;", "-="},
 		{"x *= 5;", "*="},
 		{"x /= 5;", "/="},
+		{"x %= 5;", "%="},
+		{"x **= 5;", "**="},
+		{"x &= 5;", "&="},
+		{"x |= 5;", "|="},
+		{"x ^= 5;", "^="},
+		{"x <<= 5;", "<<="},
+		{"x >>= 5;", ">>="},
 	}
 
 	for _, tt := range tests {
@@ -1368,3 +2028,269 @@ func TestPostfixOperator(t *testing.T) {
 		t.Fatalf("exp.Operator not %s. got=%s", "++", exp.Operator)
 	}
 }
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 5;", "1:5: expected next token to be IDENT, got = instead"},
+		{"let x = 5;\nlet y 10;", "2:7: expected next token to be =, got INT instead"},
+		{"let x = 5;\n\n  ) + 1;", "3:3: no prefix parse function for ) found"},
+		{"a = b = 1;", "1:1: multiple assignments in the same statement are not allowed"},
+		{"let x = 5; // fine\nx @ 1", "2:3: illegal token \"@\""},
+		{"let x = 5;\n/* never closed", "2:1: unterminated block comment"},
+		{"let s = \"never closed;\nlet y = 1;", "1:9: unterminated string literal"},
+		{"let s = \"tab\\q\";", "1:9: invalid escape sequence \\q"},
+		{"let s = \"a ${}\";", "1:14: empty string interpolation"},
+		{"let f = fn() { 1", "1:17: unexpected end of input, expected } to close the block"},
+		{"let s = \"a ${x y}\";", "1:16: unterminated string interpolation, expected }"},
+		{"match (x) { [a, a] => 1 }", "1:17: duplicate binding a in pattern"},
+		{"match (x) { a + 1 => 1 }", "1:15: expected next token to be =>, got + instead"},
+		{"match (x) { {k: v} => 1 }", "1:14: expected a literal hash pattern key, got IDENT"},
+		{"match (x) { fn => 1 }", "1:13: expected a pattern, got FUNCTION"},
+		{"match (x) { 1 => 1 2 => 2 }", "1:20: expected next token to be ,, got INT instead"},
+		{"let [a, 1] = x;", "1:9: literal pattern 1 can't be used to destructure a value"},
+		{"let [...a, b] = x;", "1:10: expected next token to be ], got , instead"},
+		{"[a] += [1];", "1:5: destructuring assignment requires =, got +="},
+		{"[a, b + 1] = x;", "1:7: cannot assign to (b + 1) in a destructuring assignment"},
+		{"[a, a] = x;", "1:5: duplicate binding a in pattern"},
+		{"let f = fn(a = 1, b) { a }", "1:19: parameter b needs a default value, it follows a parameter with one"},
+		{"let f = fn(...a, b) { a }", "1:16: expected next token to be ), got , instead"},
+		{"let f = fn(1) { }", "1:12: expected a parameter name, got INT"},
+		{"f(a: 1, a: 2);", "1:9: duplicate keyword argument a"},
+		{"f(a: 1, 2);", "1:9: positional argument follows a keyword argument"},
+		{"fn add a, b) { }", "1:8: expected next token to be (, got IDENT instead"},
+		{"for (a, a in xs) { }", "1:9: duplicate loop variable a"},
+		{"for (a, 1 in xs) { }", "1:9: expected next token to be IDENT, got INT instead"},
+		{"outer: let a = 1;", "1:8: expected a loop after label outer, got LET instead"},
+		{"xs |> len;", "1:7: the right side of |> must be a call, got len"},
+		{"xs |> f()[0];", "1:10: the right side of |> must be a call, got (f()[0])"},
+		{"[a, ...b, c] = x;", "1:5: cannot assign to ...b in a destructuring assignment"},
+		{"{...h} = x;", "1:2: cannot assign to ...h in a destructuring assignment"},
+		{"f(...);", "1:6: no prefix parse function for ) found"},
+		{"[x for (x in xs)];", "1:8: expected next token to be IDENT, got ( instead"},
+		{"[x for x, x in xs];", "1:11: duplicate loop variable x"},
+		{"[x for x in xs, 1];", "1:15: expected next token to be ], got , instead"},
+		{"{k: v for k, v in h if};", "1:23: no prefix parse function for } found"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let x 5;
+let y = (1 + ;
+let f = fn(a) {
+	let = 2;
+	a +* 1;
+	a
+};
+let ok = if (x { 1 };
+let z = 10;
+let w = @ 3;
+`
+
+	expected := []string{
+		"1:7: expected next token to be =, got INT instead",
+		"2:14: no prefix parse function for ; found",
+		"4:6: expected next token to be IDENT, got = instead",
+		"5:5: no prefix parse function for * found",
+		"8:16: expected next token to be ), got { instead",
+		"10:9: illegal token \"@\"",
+	}
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d (%q)", len(expected), len(errors), errors)
+	}
+
+	for i, msg := range expected {
+		if errors[i] != msg {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, msg, errors[i])
+		}
+	}
+
+	// the statements without errors are still parsed
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements has wrong length. expected=2, got=%d", len(program.Statements))
+	}
+
+	testLetStatement(t, program.Statements[0], "f")
+	testLetStatement(t, program.Statements[1], "z")
+
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if len(fn.Body.Statements) != 1 {
+		t.Errorf("function body has wrong length. expected=1, got=%d", len(fn.Body.Statements))
+	}
+}
+
+func TestParseErrorFields(t *testing.T) {
+	l := lexer.New("let x = 1;\nlet y 10;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.ParseErrors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. expected=1, got=%d", len(errors))
+	}
+
+	err := errors[0]
+	if err.Pos.Line != 2 || err.Pos.Column != 7 {
+		t.Errorf("err.Pos wrong. expected=2:7, got=%s", err.Pos)
+	}
+
+	if len(err.Expected) != 1 || err.Expected[0] != token.ASSIGN {
+		t.Errorf("err.Expected wrong. expected=[%s], got=%v", token.ASSIGN, err.Expected)
+	}
+
+	if err.Actual.Type != token.INT || err.Actual.Literal != "10" {
+		t.Errorf("err.Actual wrong. expected=INT 10, got=%s %s", err.Actual.Type, err.Actual.Literal)
+	}
+
+	if err.Error() != "2:7: expected next token to be =, got INT instead" {
+		t.Errorf("err.Error() wrong. got=%q", err.Error())
+	}
+}
+
+func TestIncompleteInput(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n\treturn a + b;", true},
+		{"let add = fn(a, b) {\n\treturn a + b;\n};", false},
+		{"let add = fn(a,", true},
+		{"add(1,", true},
+		{"(1 + 2", true},
+		{"[1, 2", true},
+		{"{\"a\": 1", true},
+		{"let x =", true},
+		{"if (x) { 1 } else", true},
+		{"while (true) { if (x) { break; }", true},
+		{"let s = \"never closed", true},
+		{"let s = `raw\nstring", true},
+		{"let s = \"a ${x", true},
+		{"/* still commenting", true},
+		{"let x = 5;", false},
+		{"let x 5; fn() {", false},
+		{"let x = );", false},
+		{"let s = \"bad \\q\"", false},
+		{"fn() { let = 1; ", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if p.Incomplete() != tt.incomplete {
+			t.Errorf("Incomplete() wrong for %q. expected=%t, got=%t (errors: %q)", tt.input, tt.incomplete, p.Incomplete(), p.Errors())
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `
+	// the answer
+	let x = /* inline */ 42; // trailing
+	/*
+	  block
+	*/
+	x;
+	`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	if !testLetStatement(t, program.Statements[0], "x") {
+		return
+	}
+
+	letStmt := program.Statements[0].(*ast.LetStatement)
+	if len(letStmt.Token.Comments) != 1 || letStmt.Token.Comments[0].Text != "// the answer" {
+		t.Errorf("letStmt.Token.Comments wrong. got=%+v", letStmt.Token.Comments)
+	}
+
+	if !testLiteralExpression(t, letStmt.Value, 42) {
+		return
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(x, y) {
+	x + y;
+};`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	letStmt := program.Statements[0].(*ast.LetStatement)
+	if letStmt.Pos().String() != "1:1" {
+		t.Errorf("letStmt.Pos wrong. got=%s", letStmt.Pos())
+	}
+
+	fn := letStmt.Value.(*ast.FunctionLiteral)
+	if fn.Pos().String() != "1:11" {
+		t.Errorf("fn.Pos wrong. got=%s", fn.Pos())
+	}
+
+	if fn.Parameters[1].Pos().String() != "1:17" {
+		t.Errorf("fn.Parameters[1].Pos wrong. got=%s", fn.Parameters[1].Pos())
+	}
+
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	infix := body.Expression.(*ast.InfixExpression)
+	if infix.Left.Pos().String() != "2:2" || infix.Pos().String() != "2:4" {
+		t.Errorf("infix positions wrong. got left=%s, operator=%s", infix.Left.Pos(), infix.Pos())
+	}
+}
+
+func TestMatchArmBodyPositions(t *testing.T) {
+	input := `match (x) {
+	1 => a + b,
+	_ => { c }
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	match := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+
+	// An expression body is wrapped in a block starting where the expression starts
+	if match.Arms[0].Body.Pos().String() != "2:7" {
+		t.Errorf("expression body Pos wrong. got=%s", match.Arms[0].Body.Pos())
+	}
+
+	if match.Arms[1].Body.Pos().String() != "3:7" {
+		t.Errorf("block body Pos wrong. got=%s", match.Arms[1].Body.Pos())
+	}
+}
@@ -0,0 +1,410 @@
+package parser
+
+import (
+	"cidoka/ast"
+	"cidoka/token"
+	"fmt"
+	"sort"
+)
+
+/* Token types that start a literal pattern */
+var literalPatterns = map[token.TokenType]bool{
+	token.INT:    true,
+	token.FLOAT:  true,
+	token.STRING: true,
+	token.TRUE:   true,
+	token.FALSE:  true,
+	token.NULL:   true,
+}
+
+/* Token types that can be used as keys in a hash pattern */
+var hashPatternKeys = map[token.TokenType]bool{
+	token.INT:    true,
+	token.STRING: true,
+	token.TRUE:   true,
+	token.FALSE:  true,
+}
+
+/* Parses a match expression and returns the resulting AST node */
+func (parser *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: parser.curToken}
+
+	if !parser.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	parser.nextToken()
+	expression.Subject = parser.parseExpression(LOWEST)
+
+	if !parser.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !parser.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Arms = []*ast.MatchArm{}
+
+	for !parser.peekTokenIs(token.RBRACE) {
+		parser.nextToken()
+
+		arm := parser.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+
+		expression.Arms = append(expression.Arms, arm)
+
+		// arms are separated by commas, which are optional after a block body
+		if parser.peekTokenIs(token.COMMA) {
+			parser.nextToken()
+		} else if !parser.peekTokenIs(token.RBRACE) && !parser.curTokenIs(token.RBRACE) {
+			parser.peekError(token.COMMA)
+			return nil
+		}
+	}
+
+	if !parser.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return expression
+}
+
+/*
+Parses a match arm and returns the resulting AST node
+
+An arm is a pattern, an optional guard introduced by 'if', then '=>' followed by either a block
+or a single expression
+*/
+func (parser *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: parser.curToken}
+
+	arm.Pattern = parser.parsePattern(map[string]bool{})
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if parser.peekTokenIs(token.IF) {
+		parser.nextToken()
+		parser.nextToken()
+		arm.Guard = parser.parseExpression(LOWEST)
+	}
+
+	if !parser.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	parser.nextToken()
+
+	if parser.curTokenIs(token.LBRACE) {
+		arm.Body = parser.parseBlockStatement()
+		return arm
+	}
+
+	stmt := &ast.ExpressionStatement{Token: parser.curToken}
+	stmt.Expression = parser.parseExpression(LOWEST)
+	arm.Body = &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+
+	return arm
+}
+
+/*
+Parses a pattern starting at the current token and returns the resulting AST node
+
+Names bound by the pattern are recorded in bindings, so a name can't be bound twice in the same
+pattern. It returns nil if the pattern is malformed
+*/
+func (parser *Parser) parsePattern(bindings map[string]bool) ast.Pattern {
+	switch {
+	case parser.curTokenIs(token.IDENT):
+		if parser.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: parser.curToken}
+		}
+
+		if bindings[parser.curToken.Literal] {
+			msg := fmt.Sprintf("duplicate binding %s in pattern", parser.curToken.Literal)
+			parser.addError(parser.curToken, nil, msg)
+			return nil
+		}
+		bindings[parser.curToken.Literal] = true
+
+		name := &ast.Identifier{Token: parser.curToken, Value: parser.curToken.Literal}
+		return &ast.BindingPattern{Token: parser.curToken, Name: name}
+
+	case parser.curTokenIs(token.LBRACKET):
+		return parser.parseArrayPattern(bindings)
+
+	case parser.curTokenIs(token.LBRACE):
+		return parser.parseHashPattern(bindings)
+
+	case parser.curTokenIs(token.MINUS) && (parser.peekTokenIs(token.INT) || parser.peekTokenIs(token.FLOAT)):
+		pattern := &ast.LiteralPattern{Token: parser.curToken}
+		prefix := &ast.PrefixExpression{Token: parser.curToken, Operator: parser.curToken.Literal}
+
+		parser.nextToken()
+		if prefix.Right = parser.prefixParseFns[parser.curToken.Type](); prefix.Right == nil {
+			return nil
+		}
+
+		pattern.Value = prefix
+		return pattern
+
+	case literalPatterns[parser.curToken.Type]:
+		pattern := &ast.LiteralPattern{Token: parser.curToken}
+		if pattern.Value = parser.prefixParseFns[parser.curToken.Type](); pattern.Value == nil {
+			return nil
+		}
+
+		return pattern
+
+	default:
+		msg := fmt.Sprintf("expected a pattern, got %s", parser.curToken.Type)
+		parser.addError(parser.curToken, nil, msg)
+		return nil
+	}
+}
+
+/* Parses an array pattern, e.g. [a, 2, _] or [first, ...rest], and returns the resulting AST node */
+func (parser *Parser) parseArrayPattern(bindings map[string]bool) ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: parser.curToken}
+	pattern.Elements = []ast.Pattern{}
+
+	for !parser.peekTokenIs(token.RBRACKET) {
+		parser.nextToken()
+
+		// a rest element collects the remaining elements and must come last
+		if parser.curTokenIs(token.ELLIPSIS) {
+			if !parser.expectPeek(token.IDENT) {
+				return nil
+			}
+
+			if pattern.Rest = parser.parsePattern(bindings); pattern.Rest == nil {
+				return nil
+			}
+
+			break
+		}
+
+		element := parser.parsePattern(bindings)
+		if element == nil {
+			return nil
+		}
+
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !parser.peekTokenIs(token.RBRACKET) && !parser.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !parser.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return pattern
+}
+
+/* Parses a hash pattern, e.g. {"type": t} or {name, age}, and returns the resulting AST node */
+func (parser *Parser) parseHashPattern(bindings map[string]bool) ast.Pattern {
+	pattern := &ast.HashPattern{Token: parser.curToken}
+	pattern.Keys = []ast.Expression{}
+	pattern.Values = []ast.Pattern{}
+
+	for !parser.peekTokenIs(token.RBRACE) {
+		parser.nextToken()
+
+		// a name on its own is shorthand for a string key bound to the same name, e.g. {name}
+		if parser.curTokenIs(token.IDENT) && !parser.peekTokenIs(token.COLON) {
+			value := parser.parsePattern(bindings)
+			if value == nil {
+				return nil
+			}
+
+			key := &ast.StringLiteral{Token: parser.curToken, Value: parser.curToken.Literal}
+			pattern.Keys = append(pattern.Keys, key)
+			pattern.Values = append(pattern.Values, value)
+
+			if !parser.peekTokenIs(token.RBRACE) && !parser.expectPeek(token.COMMA) {
+				return nil
+			}
+
+			continue
+		}
+
+		if !hashPatternKeys[parser.curToken.Type] {
+			msg := fmt.Sprintf("expected a literal hash pattern key, got %s", parser.curToken.Type)
+			parser.addError(parser.curToken, nil, msg)
+			return nil
+		}
+
+		key := parser.prefixParseFns[parser.curToken.Type]()
+		if key == nil {
+			return nil
+		}
+
+		if !parser.expectPeek(token.COLON) {
+			return nil
+		}
+
+		parser.nextToken()
+
+		value := parser.parsePattern(bindings)
+		if value == nil {
+			return nil
+		}
+
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+
+		if !parser.peekTokenIs(token.RBRACE) && !parser.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !parser.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return pattern
+}
+
+/*
+Parses the array or hash pattern of a destructuring let statement and returns the resulting AST node
+
+Literal patterns are rejected, since a let statement can't fail to match
+*/
+func (parser *Parser) parseDestructuringPattern() ast.Pattern {
+	pattern := parser.parsePattern(map[string]bool{})
+	if pattern == nil || !parser.checkDestructuringPattern(pattern) {
+		return nil
+	}
+
+	return pattern
+}
+
+/* Appends an error to the parser's errors list and returns false if the pattern contains a literal pattern */
+func (parser *Parser) checkDestructuringPattern(pattern ast.Pattern) bool {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		msg := fmt.Sprintf("literal pattern %s can't be used to destructure a value", pattern)
+		parser.addError(pattern.Token, nil, msg)
+		return false
+
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			if !parser.checkDestructuringPattern(element) {
+				return false
+			}
+		}
+
+	case *ast.HashPattern:
+		for _, value := range pattern.Values {
+			if !parser.checkDestructuringPattern(value) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+/*
+Converts the left side of a destructuring assignment, e.g. [a, b] in [a, b] = [b, a], to a pattern
+
+Identifiers become bindings (or the wildcard for _), array and hash literals become array and hash
+patterns. It returns nil if the expression contains anything else
+*/
+func (parser *Parser) assignmentPattern(exp ast.Expression, bindings map[string]bool) ast.Pattern {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if exp.Value == "_" {
+			return &ast.WildcardPattern{Token: exp.Token}
+		}
+
+		if bindings[exp.Value] {
+			msg := fmt.Sprintf("duplicate binding %s in pattern", exp.Value)
+			parser.addError(exp.Token, nil, msg)
+			return nil
+		}
+		bindings[exp.Value] = true
+
+		return &ast.BindingPattern{Token: exp.Token, Name: exp}
+
+	case *ast.ArrayLiteral:
+		pattern := &ast.ArrayPattern{Token: exp.Token, Elements: []ast.Pattern{}}
+
+		for i, element := range exp.Elements {
+			// a spread of a name last collects the remaining elements, like a rest pattern
+			if spread, ok := element.(*ast.SpreadExpression); ok && i == len(exp.Elements)-1 {
+				if _, ok := spread.Value.(*ast.Identifier); ok {
+					if pattern.Rest = parser.assignmentPattern(spread.Value, bindings); pattern.Rest == nil {
+						return nil
+					}
+
+					break
+				}
+			}
+
+			elementPattern := parser.assignmentPattern(element, bindings)
+			if elementPattern == nil {
+				return nil
+			}
+
+			pattern.Elements = append(pattern.Elements, elementPattern)
+		}
+
+		return pattern
+
+	case *ast.HashLiteral:
+		if len(exp.Spreads) > 0 {
+			spread := exp.Spreads[0]
+			msg := fmt.Sprintf("cannot assign to %s in a destructuring assignment", spread)
+			parser.addError(spread.Token, nil, msg)
+			return nil
+		}
+
+		pattern := &ast.HashPattern{Token: exp.Token, Keys: []ast.Expression{}, Values: []ast.Pattern{}}
+
+		// the pairs of a hash literal are unordered, keep them in source order
+		keys := []ast.Expression{}
+		for key := range exp.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Pos().Offset < keys[j].Pos().Offset })
+
+		for _, key := range keys {
+			if !isHashPatternKey(key) {
+				msg := fmt.Sprintf("expected a literal hash pattern key, got %s", key)
+				parser.addError(token.Token{Literal: key.TokenLiteral(), Pos: key.Pos()}, nil, msg)
+				return nil
+			}
+
+			value := parser.assignmentPattern(exp.Pairs[key], bindings)
+			if value == nil {
+				return nil
+			}
+
+			pattern.Keys = append(pattern.Keys, key)
+			pattern.Values = append(pattern.Values, value)
+		}
+
+		return pattern
+
+	default:
+		msg := fmt.Sprintf("cannot assign to %s in a destructuring assignment", exp)
+		parser.addError(token.Token{Literal: exp.TokenLiteral(), Pos: exp.Pos()}, nil, msg)
+		return nil
+	}
+}
+
+/* Returns true if the expression is a literal that can be used as a hash pattern key */
+func isHashPatternKey(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	default:
+		return false
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/peterh/liner"
 )
@@ -20,6 +21,7 @@ import (
 var historyFile = filepath.Join(os.TempDir(), ".cidoka_lang_history")
 
 const PROMPT = ">> "
+const CONTINUATION_PROMPT = ".. "
 
 func Start(in io.Reader, out io.Writer, engine string) {
 	var env *object.Environment
@@ -57,7 +59,6 @@ func Start(in io.Reader, out io.Writer, engine string) {
 			writeHistory(liner)
 			return
 		}
-		liner.AppendHistory(scanned)
 
 		program, err := setupProgram(scanned)
 		if err != nil {
@@ -98,9 +99,33 @@ func Start(in io.Reader, out io.Writer, engine string) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+func printParserErrors(out io.Writer, input string, errors []*parser.ParseError) {
+	lines := strings.Split(input, "\n")
+
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
+
+		if !err.Pos.IsValid() || err.Pos.Line > len(lines) {
+			continue
+		}
+
+		// show the offending line with a caret under the error's column
+		line := strings.TrimRight(lines[err.Pos.Line-1], "\r")
+		caret := []rune{}
+		for i, r := range []rune(line) {
+			if i >= err.Pos.Column-1 {
+				break
+			}
+
+			if r == '\t' {
+				caret = append(caret, '\t')
+			} else {
+				caret = append(caret, ' ')
+			}
+		}
+
+		io.WriteString(out, "\t"+line+"\n")
+		io.WriteString(out, "\t"+string(caret)+"^\n")
 	}
 }
 
@@ -122,26 +147,54 @@ func completer(line string) []string {
 	return c
 }
 
+/*
+Reads an entry from the user, which can span several lines
+
+While the entry is incomplete, e.g. a '{' or a string is still open, it keeps reading lines
+with the continuation prompt. An empty line submits an incomplete entry as is, so its errors
+are reported
+*/
 func scanInput(liner *liner.State) (string, error) {
 	var scanned string
 
+	prompt := PROMPT
 	for {
-		scannedLine, err := liner.Prompt(PROMPT)
+		scannedLine, err := liner.Prompt(prompt)
 		if err != nil {
 			return "", err
 		}
 
 		if scannedLine == "" {
-			continue
+			if scanned == "" {
+				continue
+			}
+			break
 		}
+		liner.AppendHistory(scannedLine)
 
+		if scanned != "" {
+			scanned += "\n"
+		}
 		scanned += scannedLine
-		break
+
+		if !incompleteInput(scanned) {
+			break
+		}
+		prompt = CONTINUATION_PROMPT
 	}
 
 	return scanned, nil
 }
 
+/* Returns true if the input can't be parsed yet because it ends before a construct is closed */
+func incompleteInput(input string) bool {
+	l := lexer.New(input)
+	p := parser.New(l)
+	p.ParseProgram()
+
+	return p.Incomplete()
+}
+
 func writeHistory(liner *liner.State) {
 	if f, err := os.Create(historyFile); err != nil {
 		fmt.Printf("Error writing history file: %s\n", err)
@@ -195,8 +248,8 @@ func setupProgram(input string) (*ast.Program, error) {
 	p := parser.New(l)
 	program := p.ParseProgram()
 
-	if len(p.Errors()) != 0 {
-		printParserErrors(os.Stdout, p.Errors())
+	if len(p.ParseErrors()) != 0 {
+		printParserErrors(os.Stdout, input, p.ParseErrors())
 		return nil, fmt.Errorf("parsing error")
 	}
 
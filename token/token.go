@@ -1,5 +1,7 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 const (
@@ -15,22 +17,44 @@ const (
 	FLOAT  TokenType = "FLOAT"  // 123456.0987
 	STRING TokenType = "STRING" // "foobar"
 
+	// String interpolation parts, e.g. "a ${x} b ${y} c" is INTERP_HEAD x INTERP_MID y INTERP_TAIL
+
+	INTERP_HEAD TokenType = "INTERP_HEAD" // "a ${
+	INTERP_MID  TokenType = "INTERP_MID"  // } b ${
+	INTERP_TAIL TokenType = "INTERP_TAIL" // } c"
+
 	// Assignment operators
 
-	ASSIGN      TokenType = "="  // assignment
-	PLUS_EQ     TokenType = "+=" // addition assignment
-	MINUS_EQ    TokenType = "-=" // subtraction assignment
-	ASTERISK_EQ TokenType = "*=" // multiplication assignment
-	SLASH_EQ    TokenType = "/=" // division assignment
-	MODULO_EQ   TokenType = "%=" // modulo assignment
+	ASSIGN      TokenType = "="   // assignment
+	PLUS_EQ     TokenType = "+="  // addition assignment
+	MINUS_EQ    TokenType = "-="  // subtraction assignment
+	ASTERISK_EQ TokenType = "*="  // multiplication assignment
+	SLASH_EQ    TokenType = "/="  // division assignment
+	MODULO_EQ   TokenType = "%="  // modulo assignment
+	POWER_EQ    TokenType = "**=" // exponentiation assignment
+	BIT_AND_EQ  TokenType = "&="  // bitwise and assignment
+	BIT_OR_EQ   TokenType = "|="  // bitwise or assignment
+	BIT_XOR_EQ  TokenType = "^="  // bitwise xor assignment
+	SHL_EQ      TokenType = "<<=" // left shift assignment
+	SHR_EQ      TokenType = ">>=" // right shift assignment
 
 	// Arithmetic operators
 
-	PLUS     TokenType = "+" // addition
-	MINUS    TokenType = "-" // subtraction
-	ASTERISK TokenType = "*" // multiplication
-	SLASH    TokenType = "/" // division
-	MODULO   TokenType = "%" // modulo
+	PLUS     TokenType = "+"  // addition
+	MINUS    TokenType = "-"  // subtraction
+	ASTERISK TokenType = "*"  // multiplication
+	SLASH    TokenType = "/"  // division
+	MODULO   TokenType = "%"  // modulo
+	POWER    TokenType = "**" // exponentiation
+
+	// Bitwise operators
+
+	BIT_AND TokenType = "&"  // bitwise and
+	BIT_OR  TokenType = "|"  // bitwise or
+	BIT_XOR TokenType = "^"  // bitwise xor
+	BIT_NOT TokenType = "~"  // bitwise not
+	SHL     TokenType = "<<" // left shift
+	SHR     TokenType = ">>" // right shift
 
 	// Comparison operators
 
@@ -47,6 +71,18 @@ const (
 	OR   TokenType = "||" // or
 	BANG TokenType = "!"  // negation
 
+	// Null operators
+
+	NULLISH TokenType = "??" // null coalescing
+
+	// Range operator
+
+	RANGE TokenType = ".." // range, e.g. 0..10 or 0..10:2
+
+	// Pipeline operator
+
+	PIPE TokenType = "|>" // pipeline, e.g. xs |> map(f) calls map(xs, f)
+
 	// Postfix operators
 
 	INCREMENT TokenType = "++" // increment
@@ -54,9 +90,11 @@ const (
 
 	// Delimiters
 
-	COMMA     TokenType = "," // separator
-	SEMICOLON TokenType = ";" // terminator
-	COLON     TokenType = ":" // separator
+	COMMA     TokenType = ","   // separator
+	SEMICOLON TokenType = ";"   // terminator
+	COLON     TokenType = ":"   // separator
+	ARROW     TokenType = "=>"  // match arm separator
+	ELLIPSIS  TokenType = "..." // rest element
 
 	// Brackets
 
@@ -71,8 +109,10 @@ const (
 
 	FUNCTION TokenType = "FUNCTION" // function
 	LET      TokenType = "LET"      // variable declaration
+	CONST    TokenType = "CONST"    // constant declaration
 	TRUE     TokenType = "TRUE"     // boolean true
 	FALSE    TokenType = "FALSE"    // boolean false
+	NULL     TokenType = "NULL"     // null value
 	IF       TokenType = "IF"       // if statement
 	ELSE     TokenType = "ELSE"     // else statement
 	RETURN   TokenType = "RETURN"   // return statement
@@ -80,6 +120,8 @@ const (
 	WHILE    TokenType = "WHILE"    // while loop
 	BREAK    TokenType = "BREAK"    // break statement
 	CONTINUE TokenType = "CONTINUE" // continue statement
+	MATCH    TokenType = "MATCH"    // match expression
+	IN       TokenType = "IN"       // for-in loop
 )
 
 // Map of AssignmentOperators to their TokenType constants.
@@ -90,19 +132,57 @@ var AssignmentOperators = map[TokenType]bool{
 	ASTERISK_EQ: true,
 	SLASH_EQ:    true,
 	MODULO_EQ:   true,
+	POWER_EQ:    true,
+	BIT_AND_EQ:  true,
+	BIT_OR_EQ:   true,
+	BIT_XOR_EQ:  true,
+	SHL_EQ:      true,
+	SHR_EQ:      true,
 }
 
 type Token struct {
 	Type    TokenType // Type of token
 	Literal string    // Literal value of token
+	Pos     Position  // Position of the token's first character in the input
+
+	Comments []Comment // Comments found between the previous token and this one // trivia
+}
+
+// A comment in the source input, e.g. // line comment or /* block comment */
+type Comment struct {
+	Text string   // Text of the comment including its delimiters
+	Pos  Position // Position of the comment's first character in the input
+}
+
+// A position in the source input
+type Position struct {
+	Line   int // line number, starting at 1
+	Column int // column number, starting at 1
+	Offset int // byte offset, starting at 0
+}
+
+/* Returns true if the position was set by the lexer */
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+/* Returns the position formatted as line:column */
+func (pos Position) String() string {
+	if !pos.IsValid() {
+		return "-"
+	}
+
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
 }
 
 // Map of Keywords to their TokenType constants.
 var Keywords = map[string]TokenType{
 	"fn":       FUNCTION,
 	"let":      LET,
+	"const":    CONST,
 	"true":     TRUE,
 	"false":    FALSE,
+	"null":     NULL,
 	"if":       IF,
 	"else":     ELSE,
 	"return":   RETURN,
@@ -110,6 +190,8 @@ var Keywords = map[string]TokenType{
 	"while":    WHILE,
 	"break":    BREAK,
 	"continue": CONTINUE,
+	"match":    MATCH,
+	"in":       IN,
 }
 
 /*
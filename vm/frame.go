@@ -9,6 +9,7 @@ type Frame struct {
 	obj         object.Object
 	ip          int
 	basePointer int
+	numArgs     int // number of arguments passed to the function, excluding the ones collected by a rest parameter
 }
 
 func NewFrame(obj object.Object, basePointer int) *Frame {
@@ -29,3 +30,14 @@ func (f *Frame) Instructions() code.Instructions {
 		return nil
 	}
 }
+
+func (f *Frame) SourceMap() code.SourceMap {
+	switch obj := f.obj.(type) {
+	case *object.Closure:
+		return obj.Fn.SourceMap
+	case *object.CompiledLoop:
+		return obj.SourceMap
+	default:
+		return nil
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"cidoka/compiler"
 	"cidoka/object"
 	"fmt"
+	"math"
+	"strings"
 )
 
 const StackSize = 2048
@@ -28,7 +30,7 @@ type VM struct {
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
@@ -59,6 +61,26 @@ func (vm *VM) LastPoppedStackElem() object.Object {
 }
 
 func (vm *VM) Run() error {
+	err := vm.run()
+	if err != nil {
+		return vm.positionError(err)
+	}
+
+	return nil
+}
+
+func (vm *VM) positionError(err error) error {
+	frame := vm.currentFrame()
+
+	pos, ok := frame.SourceMap().Lookup(frame.ip)
+	if !ok {
+		return err
+	}
+
+	return fmt.Errorf("%s: %w", pos, err)
+}
+
+func (vm *VM) run() error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
@@ -89,12 +111,18 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
 			}
 
+		case code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShl, code.OpShr:
+			err := vm.executeBitwiseOperation(op)
+			if err != nil {
+				return err
+			}
+
 		case code.OpTrue:
 			err := vm.push(True)
 			if err != nil {
@@ -113,6 +141,20 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpIn:
+			container := vm.pop()
+			value := vm.pop()
+
+			found, err := object.Contains(container, value)
+			if err != nil {
+				return err
+			}
+
+			err = vm.push(nativeBoolToBooleanObject(found))
+			if err != nil {
+				return err
+			}
+
 		case code.OpAnd, code.OpOr:
 			err := vm.executeLogicalOperation(op)
 			if err != nil {
@@ -131,6 +173,12 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpBitNot:
+			err := vm.executeBitNotOperator()
+			if err != nil {
+				return err
+			}
+
 		case code.OpJumpNotTruthy:
 			pos := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -144,6 +192,26 @@ func (vm *VM) Run() error {
 			pos := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip = pos - 1
 
+		case code.OpJumpNotNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1].Type() != object.NULL_OBJ {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpJumpArgPassed:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			argIndex := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			frame := vm.currentFrame()
+			if argIndex < frame.numArgs && vm.stack[frame.basePointer+argIndex] != nil {
+				vm.currentFrame().ip = pos - 1
+			}
+
 		case code.OpDeclareGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
@@ -208,6 +276,93 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpArraySpread:
+			value := vm.pop()
+
+			elements, err := object.SpreadElements(value)
+			if err != nil {
+				return err
+			}
+
+			// the array below was built by the literal being evaluated, so it can be extended in place
+			array := vm.stack[vm.sp-1].(*object.Array)
+			array.Elements = append(array.Elements, elements...)
+
+		case code.OpHashSpread:
+			value := vm.pop()
+
+			err := object.SpreadPairs(vm.stack[vm.sp-1].(*object.Hash), value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			hasRest := code.ReadUint8(ins[ip+3:]) == 1
+			vm.currentFrame().ip += 3
+
+			array, ok := vm.pop().(*object.Array)
+			matched := ok && (len(array.Elements) == numElements || hasRest && len(array.Elements) > numElements)
+
+			err := vm.push(nativeBoolToBooleanObject(matched))
+			if err != nil {
+				return err
+			}
+
+		case code.OpArrayRest:
+			start := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			err := vm.executeArrayRest(vm.pop(), start)
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchHash:
+			numKeys := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			matched := vm.hashHasKeys(vm.stack[vm.sp-numKeys-1], vm.sp-numKeys, vm.sp)
+			vm.sp = vm.sp - numKeys - 1
+
+			err := vm.push(nativeBoolToBooleanObject(matched))
+			if err != nil {
+				return err
+			}
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := vm.buildString(vm.sp-numParts, vm.sp)
+			vm.sp = vm.sp - numParts
+
+			err := vm.push(str)
+			if err != nil {
+				return err
+			}
+
+		case code.OpRange:
+			numBounds := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			var step object.Object
+			if numBounds == 3 {
+				step = vm.stack[vm.sp-1]
+			}
+
+			rng, err := object.NewRange(vm.stack[vm.sp-numBounds], vm.stack[vm.sp-numBounds+1], step)
+			if err != nil {
+				return err
+			}
+
+			vm.sp = vm.sp - numBounds
+
+			err = vm.push(rng)
+			if err != nil {
+				return err
+			}
+
 		case code.OpHash:
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -234,6 +389,21 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			slice, err := object.Slice(left, start, end)
+			if err != nil {
+				return err
+			}
+
+			err = vm.push(slice)
+			if err != nil {
+				return err
+			}
+
 		case code.OpGetIndex:
 			index := vm.pop()
 			left := vm.pop()
@@ -252,10 +422,47 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpCallKeywords:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			namesIndex := code.ReadUint16(ins[ip+2:])
+			vm.currentFrame().ip += 3
+
+			err := vm.executeKeywordCall(int(numArgs), vm.constants[namesIndex].(*object.Array))
+			if err != nil {
+				return err
+			}
+
+		case code.OpCallSpread:
+			numArgs, err := vm.spreadArguments(0)
+			if err != nil {
+				return err
+			}
+
+			err = vm.executeCall(numArgs)
+			if err != nil {
+				return err
+			}
+
+		case code.OpCallSpreadKeywords:
+			namesIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			names := vm.constants[namesIndex].(*object.Array)
+
+			numArgs, err := vm.spreadArguments(len(names.Elements))
+			if err != nil {
+				return err
+			}
+
+			err = vm.executeKeywordCall(numArgs, names)
+			if err != nil {
+				return err
+			}
+
 		case code.OpReturnValue:
 			returnValue := vm.pop()
 
-			frame := vm.popFrame()
+			frame := vm.popFunctionFrame()
 			vm.sp = frame.basePointer - 1
 
 			err := vm.push(returnValue)
@@ -264,7 +471,7 @@ func (vm *VM) Run() error {
 			}
 
 		case code.OpReturn:
-			frame := vm.popFrame()
+			frame := vm.popFunctionFrame()
 			vm.sp = frame.basePointer - 1
 
 			err := vm.push(Null)
@@ -303,11 +510,37 @@ func (vm *VM) Run() error {
 			}
 
 			freeVar := currentLoop.Free[freeIndex]
+			if freeVar.Kind != object.LocalVariable {
+				return fmt.Errorf("attempting to modify a variable captured by a closure in a for loop")
+			}
+
 			idx := vm.nFrame(freeVar.Scope).basePointer + int(freeVar.Index)
 			val := vm.pop()
 			vm.stack[idx] = val
 			vm.push(val)
 
+		case code.OpHoistClosure:
+			err := vm.push(&object.Closure{})
+			if err != nil {
+				return err
+			}
+
+		case code.OpFillClosure:
+			// The hoisted closure is replaced if its variable was reassigned before the declaration
+			hoisted := vm.pop()
+			closure := vm.pop().(*object.Closure)
+
+			if empty, ok := hoisted.(*object.Closure); ok && empty.Fn == nil {
+				empty.Fn = closure.Fn
+				empty.Free = closure.Free
+				closure = empty
+			}
+
+			err := vm.push(closure)
+			if err != nil {
+				return err
+			}
+
 		case code.OpGetFree:
 			freeIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
@@ -319,9 +552,7 @@ func (vm *VM) Run() error {
 					return err
 				}
 			case *object.CompiledLoop:
-				freeVar := current.Free[freeIndex]
-				idx := vm.nFrame(freeVar.Scope).basePointer + int(freeVar.Index)
-				err := vm.push(vm.stack[idx])
+				err := vm.push(vm.getLoopVariable(current.Free[freeIndex]))
 				if err != nil {
 					return err
 				}
@@ -358,6 +589,56 @@ func (vm *VM) Run() error {
 
 			frame := vm.popFrame()
 			vm.sp = frame.basePointer
+
+		case code.OpBreakOuter:
+			depth := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			frame, err := vm.popLoopFrames(depth + 1)
+			if err != nil {
+				return err
+			}
+
+			vm.sp = frame.basePointer
+
+		case code.OpContinueOuter:
+			depth := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			frame, err := vm.popLoopFrames(depth)
+			if err != nil {
+				return err
+			}
+
+			vm.sp = frame.basePointer
+
+			loop, ok := vm.currentFrame().obj.(*object.CompiledLoop)
+			if !ok {
+				return fmt.Errorf("continue statement outside for loop: %+v", vm.currentFrame().obj)
+			}
+
+			vm.currentFrame().ip = loop.ContinuePos - 1
+
+		case code.OpIter:
+			obj := vm.pop()
+			iterable, ok := obj.(object.Iterable)
+			if !ok {
+				return fmt.Errorf("cannot iterate over %s", obj.Type())
+			}
+
+			err := vm.push(iterable.Iterator())
+			if err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			numVariables := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			err := vm.executeIterNext(vm.pop().(object.Iterator), int(numVariables))
+			if err != nil {
+				return err
+			}
 		}
 
 	}
@@ -405,6 +686,67 @@ func (vm *VM) popFrame() *Frame {
 	return vm.frames[vm.framesIndex]
 }
 
+// Pops the frames of the loops a return statement is in, then the frame of the function it returns from
+func (vm *VM) popFunctionFrame() *Frame {
+	for {
+		frame := vm.popFrame()
+		if _, ok := frame.obj.(*object.CompiledLoop); !ok {
+			return frame
+		}
+	}
+}
+
+// Pops the frames of the n innermost loops and returns the last one popped
+func (vm *VM) popLoopFrames(n int) (*Frame, error) {
+	var frame *Frame
+	for i := 0; i < n; i++ {
+		if _, ok := vm.currentFrame().obj.(*object.CompiledLoop); !ok {
+			return nil, fmt.Errorf("break statement outside for loop: %+v", vm.currentFrame().obj)
+		}
+
+		frame = vm.popFrame()
+	}
+
+	return frame, nil
+}
+
+// Returns a variable of the enclosing scopes used in the body of the current loop
+func (vm *VM) getLoopVariable(freeVar object.FreeVariable) object.Object {
+	frame := vm.nFrame(freeVar.Scope)
+
+	switch freeVar.Kind {
+	case object.ClosureFreeVariable:
+		return frame.obj.(*object.Closure).Free[freeVar.Index]
+	case object.CurrentClosureVariable:
+		return frame.obj
+	default:
+		return vm.stack[frame.basePointer+freeVar.Index]
+	}
+}
+
+func (vm *VM) executeIterNext(iterator object.Iterator, numVariables int) error {
+	key, value, ok := iterator.Next()
+	if !ok {
+		return vm.push(False)
+	}
+
+	if numVariables == 2 {
+		err := vm.push(key)
+		if err != nil {
+			return err
+		}
+	} else {
+		value = object.LoopVariable(iterator, key, value)
+	}
+
+	err := vm.push(value)
+	if err != nil {
+		return err
+	}
+
+	return vm.push(True)
+}
+
 func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
@@ -440,6 +782,12 @@ func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.O
 		result = leftVal / rightVal
 	case code.OpMod:
 		result = leftVal % rightVal
+	case code.OpPow:
+		power, err := object.IntegerPow(leftVal, rightVal)
+		if err != nil {
+			return err
+		}
+		result = power
 	default:
 		return fmt.Errorf("unknown integer operator: %d", op)
 	}
@@ -461,6 +809,8 @@ func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left, right object.Obj
 		result = leftVal * rightVal
 	case code.OpDiv:
 		result = leftVal / rightVal
+	case code.OpPow:
+		result = math.Pow(leftVal, rightVal)
 	default:
 		return fmt.Errorf("unknown float operator: %d", op)
 	}
@@ -483,6 +833,43 @@ func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Ob
 	return vm.push(&object.String{Value: result})
 }
 
+func (vm *VM) executeBitwiseOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftType := left.Type()
+	rightType := right.Type()
+
+	if leftType != object.INTEGER_OBJ || rightType != object.INTEGER_OBJ {
+		return fmt.Errorf("unsupported types for bitwise operation: %s %s", leftType, rightType)
+	}
+
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	if (op == code.OpShl || op == code.OpShr) && rightVal < 0 {
+		return fmt.Errorf("negative shift count: %d", rightVal)
+	}
+
+	var result int64
+	switch op {
+	case code.OpBitAnd:
+		result = leftVal & rightVal
+	case code.OpBitOr:
+		result = leftVal | rightVal
+	case code.OpBitXor:
+		result = leftVal ^ rightVal
+	case code.OpShl:
+		result = leftVal << rightVal
+	case code.OpShr:
+		result = leftVal >> rightVal
+	default:
+		return fmt.Errorf("unknown bitwise operator: %d", op)
+	}
+
+	return vm.push(&object.Integer{Value: result})
+}
+
 func (vm *VM) executeComparison(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
@@ -495,6 +882,18 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 		return vm.executeIntegerComparison(op, left, right)
 	case leftType == object.FLOAT_OBJ && rightType == object.FLOAT_OBJ:
 		return vm.executeFloatComparison(op, left, right)
+	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
+		return vm.executeStringComparison(op, left, right)
+	}
+
+	// null is only equal to itself
+	if leftType == object.NULL_OBJ || rightType == object.NULL_OBJ {
+		switch op {
+		case code.OpEqual:
+			return vm.push(nativeBoolToBooleanObject(leftType == rightType))
+		case code.OpNotEqual:
+			return vm.push(nativeBoolToBooleanObject(leftType != rightType))
+		}
 	}
 
 	switch op {
@@ -551,6 +950,20 @@ func (vm *VM) executeFloatComparison(op code.Opcode, left, right object.Object)
 	}
 }
 
+func (vm *VM) executeStringComparison(op code.Opcode, left, right object.Object) error {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal == rightVal))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftVal != rightVal))
+	default:
+		return fmt.Errorf("unknown string operator: %d", op)
+	}
+}
+
 func (vm *VM) executeLogicalOperation(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
@@ -597,6 +1010,16 @@ func (vm *VM) executeMinusOperator() error {
 	}
 }
 
+func (vm *VM) executeBitNotOperator() error {
+	operand := vm.pop()
+	if operand.Type() != object.INTEGER_OBJ {
+		return fmt.Errorf("unsupported type for bitwise negation: %s", operand.Type())
+	}
+
+	value := operand.(*object.Integer).Value
+	return vm.push(&object.Integer{Value: ^value})
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return True
@@ -627,6 +1050,16 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 	return &object.Array{Elements: elements}
 }
 
+func (vm *VM) buildString(startIndex, endIndex int) object.Object {
+	var out strings.Builder
+
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(object.Stringify(vm.stack[i]))
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
 	hashedPairs := make(map[object.HashKey]object.HashPair)
 
@@ -647,6 +1080,41 @@ func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
 	return &object.Hash{Pairs: hashedPairs}, nil
 }
 
+// Reports whether obj is a hash containing every key in the stack between startIndex and endIndex
+func (vm *VM) hashHasKeys(obj object.Object, startIndex, endIndex int) bool {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return false
+	}
+
+	for i := startIndex; i < endIndex; i++ {
+		key, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return false
+		}
+
+		if _, ok := hash.Pairs[key.HashKey()]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (vm *VM) executeArrayRest(obj object.Object, start int) error {
+	array, ok := obj.(*object.Array)
+	if !ok {
+		return fmt.Errorf("rest element not supported: %s", obj.Type())
+	}
+
+	elements := []object.Object{}
+	if start < len(array.Elements) {
+		elements = append(elements, array.Elements[start:]...)
+	}
+
+	return vm.push(&object.Array{Elements: elements})
+}
+
 func (vm *VM) executeSetIndexExpression(left, index, newVal object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -660,11 +1128,10 @@ func (vm *VM) executeSetIndexExpression(left, index, newVal object.Object) error
 
 func (vm *VM) executeArrayIndexSet(array, index, newVal object.Object) error {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	idx, ok := object.ResolveIndex(index.(*object.Integer).Value, int64(len(arrayObject.Elements)))
 
-	if idx < 0 || idx > max {
-		return fmt.Errorf("index out of range: %d", idx)
+	if !ok {
+		return fmt.Errorf("index out of range: %d", index.(*object.Integer).Value)
 	}
 
 	arrayObject.Elements[idx] = newVal
@@ -687,6 +1154,10 @@ func (vm *VM) executeGetIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeGetArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeGetStringIndex(left, index)
+	case left.Type() == object.RANGE_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeGetRangeIndex(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeGetHashIndex(left, index)
 	default:
@@ -696,16 +1167,37 @@ func (vm *VM) executeGetIndexExpression(left, index object.Object) error {
 
 func (vm *VM) executeGetArrayIndex(array, index object.Object) error {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	idx, ok := object.ResolveIndex(index.(*object.Integer).Value, int64(len(arrayObject.Elements)))
 
-	if idx < 0 || idx > max {
+	if !ok {
 		return vm.push(Null)
 	}
 
 	return vm.push(arrayObject.Elements[idx])
 }
 
+func (vm *VM) executeGetStringIndex(str, index object.Object) error {
+	runes := []rune(str.(*object.String).Value)
+	idx, ok := object.ResolveIndex(index.(*object.Integer).Value, int64(len(runes)))
+
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(&object.String{Value: string(runes[idx])})
+}
+
+func (vm *VM) executeGetRangeIndex(rng, index object.Object) error {
+	rangeObject := rng.(*object.Range)
+	idx, ok := object.ResolveIndex(index.(*object.Integer).Value, rangeObject.Len())
+
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(rangeObject.At(idx))
+}
+
 func (vm *VM) executeGetHashIndex(hash, index object.Object) error {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
@@ -733,12 +1225,107 @@ func (vm *VM) executeCall(numArgs int) error {
 	}
 }
 
+// Replaces the array of positional arguments below the values of numKeywords keyword arguments with its elements
+// Returns the number of positional arguments
+func (vm *VM) spreadArguments(numKeywords int) (int, error) {
+	argsIndex := vm.sp - 1 - numKeywords
+	args := vm.stack[argsIndex].(*object.Array).Elements
+
+	keywords := make([]object.Object, numKeywords)
+	copy(keywords, vm.stack[argsIndex+1:vm.sp])
+	vm.sp = argsIndex
+
+	for _, values := range [][]object.Object{args, keywords} {
+		for _, value := range values {
+			err := vm.push(value)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(args), nil
+}
+
+// Matches the keyword arguments to the parameters of the callee and calls it with the arguments in parameter order
+func (vm *VM) executeKeywordCall(numArgs int, names *object.Array) error {
+	numKeywords := len(names.Elements)
+	calleeIndex := vm.sp - 1 - numArgs - numKeywords
+
+	var params []string
+	var required int
+
+	switch callee := vm.stack[calleeIndex].(type) {
+	case *object.Closure:
+		if callee.Fn == nil {
+			return fmt.Errorf("calling a function before its declaration")
+		}
+		params = callee.Fn.Parameters
+		required = callee.Fn.NumParameters - callee.Fn.NumDefaults
+	case *object.Builtin:
+		if callee.Parameters == nil {
+			return fmt.Errorf("builtin function doesn't accept keyword arguments")
+		}
+		params = callee.Parameters
+		required = len(callee.Parameters)
+	default:
+		return fmt.Errorf("calling non-function and non-built-in")
+	}
+
+	keywords := make([]string, numKeywords)
+	for i, name := range names.Elements {
+		keywords[i] = name.(*object.String).Value
+	}
+
+	positional := vm.stack[calleeIndex+1 : calleeIndex+1+numArgs]
+	values := vm.stack[calleeIndex+1+numArgs : vm.sp]
+
+	args, err := object.MatchKeywordArguments(params, required, positional, keywords, values)
+	if err != nil {
+		return err
+	}
+
+	// Parameters that weren't passed are left nil, so OpJumpArgPassed evaluates their default
+	copy(vm.stack[calleeIndex+1:], args)
+	vm.sp = calleeIndex + 1 + len(args)
+
+	return vm.executeCall(len(args))
+}
+
 func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
-	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	fn := cl.Fn
+	if fn == nil {
+		return fmt.Errorf("calling a function before its declaration")
+	}
+
+	required := fn.NumParameters - fn.NumDefaults
+
+	if numArgs < required || !fn.Variadic && numArgs > fn.NumParameters {
+		want := object.DescribeArity(required, fn.NumParameters, fn.Variadic)
+		return fmt.Errorf("wrong number of arguments: want=%s, got=%d", want, numArgs)
+	}
+
+	basePointer := vm.sp - numArgs
+	if basePointer+fn.NumLocals >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	frame := NewFrame(cl, basePointer)
+	frame.numArgs = numArgs
+
+	// The extra arguments are collected into the rest parameter, the local after the parameters
+	if fn.Variadic {
+		restPointer := basePointer + fn.NumParameters
+		rest := []object.Object{}
+
+		if numArgs > fn.NumParameters {
+			rest = append(rest, vm.stack[restPointer:vm.sp]...)
+			frame.numArgs = fn.NumParameters
+		}
+
+		vm.stack[restPointer] = &object.Array{Elements: rest}
 	}
 
-	frame := NewFrame(cl, vm.sp-numArgs)
 	vm.pushFrame(frame)
 
 	vm.sp = frame.basePointer + cl.Fn.NumLocals
@@ -24,6 +24,33 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"-10", -10},
 		{"-50 + 100 + -50", 0},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"0xFF + 0o17 + 0b1010", 280},
+		{"1_000 * 2", 2000},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"2 * 3 ** 2", 18},
+		{"7 ** 0", 1},
+		{"(-2) ** 63 == -(2 ** 62) * 2", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"0b1100 & 0b1010", 8},
+		{"0b1100 | 0b1010", 14},
+		{"0b1100 ^ 0b1010", 6},
+		{"~0", -1},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"0xFF & 1 << 4", 16},
+		{"6 & 3 == 2", true},
+		{"let flags = 0; flags |= 1 << 3; flags ^= 0b1001; flags", 1},
+		{"let x = 0xF0; x &= 0x3C; x >>= 2; x <<= 1; x", 24},
 	}
 
 	runVmTests(t, tests)
@@ -109,11 +136,135 @@ func TestGlobalLetStatements(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"const one = 1; one", 1},
+		{"const [a, b] = [1, 2]; a + b", 3},
+		{"const xs = [1, 2]; xs[0] = 5; xs", []int{5, 2}},
+		{"const x = 1; let f = fn() { let x = 2; x = 3; x }; f() + x", 4},
+		{"const x = 10; let f = fn() { x * 2 }; f()", 20},
+		{"let r = []; for (i in 0..3) { const sq = i * i; r = push(r, sq) } r", []int{0, 1, 4}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestNull(t *testing.T) {
+	tests := []vmTestCase{
+		{"null", Null},
+		{"null == null", true},
+		{"null != null", false},
+		{"1 == null", false},
+		{"null != 1", true},
+		{`[1][5] == null`, true},
+		{`{"a": 1}["b"] == null`, true},
+		{`fn() {}() == null`, true},
+		{`!null`, true},
+		{`null ?? 5`, 5},
+		{`0 ?? 5`, 0},
+		{`false ?? 5`, false},
+		{`null ?? null ?? "c"`, "c"},
+		{`let cfg = {"port": 80}; cfg["host"] ?? "localhost"`, "localhost"},
+		{`let calls = 0; let f = fn() { calls += 1; 1 }; 2 ?? f(); calls`, 0},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`match (1) { 1 => "one", _ => "other" }`, "one"},
+		{`match (2) { 1 => "one", _ => "other" }`, "other"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{`match (-2) { -2 => "minus two" }`, "minus two"},
+		{`match (null) { 0 => 0, null => 1 }`, 1},
+		{`match (5) { 1 => 1 }`, Null},
+		{`match (5) { 5 => {} }`, Null},
+		{`match ([1, [2, 3]]) { [a] => a, [a, [b, c]] => a + b + c }`, 6},
+		{`match ([1, 2]) { [a, b, c] => 0, [_, b] => b }`, 2},
+		{`match ({"type": "circle", "r": 2}) { {"type": "square", "side": s} => s, {"type": "circle", "r": r} => r * 3 }`, 6},
+		{`match ({"a": 1}) { {"b": b} => b, {} => "empty pattern" }`, "empty pattern"},
+		{`match (1) { [] => 1, {} => 2, _ => 3 }`, 3},
+		{`match (4) { n if n > 5 => "big", n if n > 2 => "medium", _ => "small" }`, "medium"},
+		{`let x = 10; match (1) { x => x }; x`, 10},
+		{`match (3) { x => { let y = x * 2; y + 1 } }`, 7},
+		{`let f = fn(l) { match (l) { [h, t] => h + f(t), [] => 0 } }; f([1, [2, [3, []]]])`, 6},
+		{`let g = fn(x) { match (x) { n => fn() { n * 2 } } }; g(21)()`, 42},
+		{`match (match (1) { 1 => 2 }) { 2 => match (3) { x => x } }`, 3},
+		{`let f = fn() { match (1) { 1 => { return 5; } }; 0 }; f()`, 5},
+		{`let r = 0; for (let i = 0; i < 4; i++) { r += match (i) { 0 => 10, 1 => { continue; }, _ => i } } r`, 15},
+		{`let r = 0; for (let i = 0; i < 4; i++) { match (i) { 2 => { break; }, _ => { r += 1 } } } r`, 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []vmTestCase{
+		{`let [a, b, ...rest] = [1, 2, 3, 4]; [a, b, rest]`, []interface{}{1, 2, []interface{}{3, 4}}},
+		{`let [a, ...rest] = [1]; rest`, []interface{}{}},
+		{`let [x, [y, z]] = [1, [2]]; [x, y, z]`, []interface{}{1, 2, Null}},
+		{`let [_, second] = [1, 2]; second`, 2},
+		{`let {name, age} = {"name": "Ana", "age": 30}; "${name} ${age}"`, "Ana 30"},
+		{`let {"k": v, 1: w} = {"k": 1, 1: 2}; v + w`, 3},
+		{`let {missing} = {}; missing`, Null},
+		{`let pair = fn() { [3, 4] }; let [a, b] = pair(); a * b`, 12},
+		{`let f = fn() { let [a, b] = [3, 4]; a * b }; f()`, 12},
+		{`let a = 1; let b = 2; [a, b] = [b, a]; [a, b]`, []interface{}{2, 1}},
+		{`let f = fn() { let x = 1; let y = 2; [x, y] = [y, x]; x - y }; f()`, 1},
+		{`let a = 0; let b = 0; {"x": a, "y": b} = {"x": 5, "y": 6}; a * b`, 30},
+		{`let x = 0; [x] = [9]`, []interface{}{9}},
+		{`match ([1, 2, 3]) { [h, ...t] => t, _ => 0 }`, []interface{}{2, 3}},
+		{`match ([1]) { [h, _, ...t] => t, [h, ..._] => h }`, 1},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestStringExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{`"monkey"`, "monkey"},
 		{`"mon" + "key"`, "monkey"},
 		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`"mon" + "key" == "monkey"`, true},
+		{`"monkey" == "donkey"`, false},
+		{`"monkey" != "donkey"`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []vmTestCase{
+		{`"a\tb"`, "a\tb"},
+		{`"say \"hi\"\n"`, "say \"hi\"\n"},
+		{`len("a\\b")`, 3},
+		{`"\u{63}ido" + "ka"`, "cidoka"},
+		{"`raw\n\\t`", "raw\n\\t"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []vmTestCase{
+		{`let name = "Ana"; "hello ${name}!"`, "hello Ana!"},
+		{`let items = [1, 2]; "${len(items)} items: ${items}"`, "2 items: [1, 2]"},
+		{`"${1 + 2} ${true} ${if (false) { 1 }}"`, "3 true null"},
+		{`let f = fn(x) { "<${x}>" }; "${f("a")}${f(1)}"`, "<a><1>"},
+		{`"outer ${"inner ${"deep"}"}"`, "outer inner deep"},
+		{`"\${not} $interpolated"`, "${not} $interpolated"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	tests := []vmTestCase{
+		{`let año = 2024; año + 1`, 2025},
+		{`let saludar = fn(niño) { "hola " + niño }; saludar("José")`, "hola José"},
+		{`len("ñandú")`, 5},
+		{`len("😀")`, 1},
 	}
 
 	runVmTests(t, tests)
@@ -159,7 +310,12 @@ func TestIndexExpressions(t *testing.T) {
 		{"[[1, 1, 1]][0][0]", 1},
 		{"[][0]", Null},
 		{"[1, 2, 3][99]", Null},
-		{"[1][-1]", Null},
+		{"[1][-1]", 1},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][-4]", Null},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[5]`, Null},
 		{"{1: 1, 2: 2}[1]", 1},
 		{"{1: 1, 2: 2}[2]", 2},
 		{"{1: 1}[0]", Null},
@@ -169,6 +325,46 @@ func TestIndexExpressions(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestRangeExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"0..5", &object.Range{Start: 0, End: 5, Step: 1}},
+		{"let n = 3; 1..n + 1:n - 1", &object.Range{Start: 1, End: 4, Step: 2}},
+		{"10..0:-3", &object.Range{Start: 10, End: 0, Step: -3}},
+		{"(0..10:3)[1]", 3},
+		{"(0..10:3)[4]", Null},
+		{"(10..0:-3)[3]", 1},
+		{"(0..5)[-1]", 4},
+		{"(0..5)[-6]", Null},
+		{"let s = 0; for (i in 1..101) { s += i } s", 5050},
+		{"let r = []; for (i, x in 10..0:-4) { r = push(r, i * 100 + x) } r", []int{10, 106, 202}},
+		{"let n = 0; for (x in 5..1) { n += 1 } n", 0},
+		{"let n = 0; for (x in 0..1000000000000) { if (x == 3) { break } n += 1 } n", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:-1]", []int{1, 2, 3}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-10:10]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+		{"let a = [1, 2]; let b = a[:]; b[0] = 9; a", []int{1, 2}},
+		{"let a = [1, 2, 3]; let i = 1; a[i:i + 1]", []int{2}},
+		{`"héllo"[2:]`, "llo"},
+		{`"héllo"[:-3]`, "hé"},
+		{`"héllo"[4:2]`, ""},
+		{"(0..10:3)[1:]", &object.Range{Start: 3, End: 10, Step: 3}},
+		{"(0..10)[2:-5]", &object.Range{Start: 2, End: 5, Step: 1}},
+		{"(10..0:-1)[:2]", &object.Range{Start: 10, End: 8, Step: -1}},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestCallingFunctionsWithoutArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -402,19 +598,348 @@ func TestCallingFunctionsWithArgumentsAndBindings(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{`let f = fn(a, b = 2) { a + b }; f(1)`, 3},
+		{`let f = fn(a, b = 2) { a + b }; f(1, 5)`, 6},
+		{`let f = fn(a = 1, b = a * 2) { [a, b] }; f()`, []interface{}{1, 2}},
+		{`let f = fn(a = 1, b = a * 2) { [a, b] }; f(3)`, []interface{}{3, 6}},
+		{`let f = fn(a = null) { a }; f()`, Null},
+		{`let x = 1; let f = fn(a = x) { a }; x = 7; f()`, 7},
+		{`let make = fn(n) { fn(a = n) { a } }; make(4)()`, 4},
+		{`let f = fn(...rest) { rest }; f()`, []interface{}{}},
+		{`let f = fn(a, ...rest) { [a, rest] }; f(1, 2, 3)`, []interface{}{1, []interface{}{2, 3}}},
+		{`let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1)`, []interface{}{1, 2, []interface{}{}}},
+		{`let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 3, 5)`, []interface{}{1, 3, []interface{}{5}}},
+		{`let f = fn(...rest) { let x = len(rest); x }; f(1, 2)`, 2},
+		{`let b = 10; let f = fn(a = b, b = 1, c = a + b) { [a, b, c] }; [f(), f(b: 2)]`, []interface{}{[]interface{}{10, 1, 11}, []interface{}{10, 2, 12}}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDefaultParameterForwardReferences(t *testing.T) {
+	// A default can't see the parameters after it, whose slots aren't set yet when it runs
+	tests := []vmTestCase{
+		{`let f = fn(a = b, b = 1) { a + 1 }; f()`, `1:16: undefined variable b`},
+		{`let g = fn(x, y, z) { x }; g(1, 2, 3); let f = fn(a = b, b = 1) { a }; f()`, `1:55: undefined variable b`},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err == nil {
+			t.Fatalf("expected compiler error for %q but resulted in none.", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong compiler error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []vmTestCase{
+		{`fn add(a, b) { a + b } add(1, 2)`, 3},
+		{`fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } } fact(5)`, 120},
+		{`
+		fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+		fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+		[isEven(10), isOdd(7)]
+		`, []interface{}{true, true}},
+		{`
+		fn outer(x) {
+			fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+			fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+			isEven(x)
+		}
+		[outer(4), outer(5)]
+		`, []interface{}{true, false}},
+		{`fn outer() { let g = fn() { h() }; fn h() { 7 } g() } outer()`, 7},
+		{`fn outer() { let r = 0; for (let i = 0; i < 3; i++) { r = r + sq(i) } r } fn sq(x) { x * x } outer()`, 5},
+		{`let base = 10; fn addBase(a) { a + base } addBase(1)`, 11},
+		{`fn f() { 1 } f = 5; f`, 5},
+		{`let g = h; fn h() { 1 } g()`, 1},
+		{`fn outer() { let fs = [h]; fn h(x) { x * 2 } fs[0](4) } outer()`, 8},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestKeywordArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{`let f = fn(a, b) { a - b }; f(b: 1, a: 5)`, 4},
+		{`let f = fn(a, b) { a - b }; f(5, b: 1)`, 4},
+		{`let f = fn(a, b = 2, c = 3) { [a, b, c] }; f(1, c: 9)`, []interface{}{1, 2, 9}},
+		{`let f = fn(a, b = a + 1, c = b + 1) { [a, b, c] }; f(c: 0, a: 1)`, []interface{}{1, 2, 0}},
+		{`let f = fn(a, ...rest) { [a, rest] }; f(a: 1)`, []interface{}{1, []interface{}{}}},
+		{`let g = fn(x) { let h = fn(a, b = 2) { a * b }; h(b: x, a: 3) }; g(4)`, 12},
+		{`push(element: 3, array: [1, 2])`, []interface{}{1, 2, 3}},
+		{`len(value: "abc")`, 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestInOperator(t *testing.T) {
+	tests := []vmTestCase{
+		{`2 in [1, 2, 3]`, true},
+		{`"c" in ["a", "b"]`, false},
+		{`null in [1, null]`, true},
+		{`let h = {"a": null}; ["a" in h, "b" in h, h["a"] != null]`, []interface{}{true, false, false}},
+		{`"ell" in "hello"`, true},
+		{`[4 in 0..10:2, 5 in 0..10:2, 10 in 0..10]`, []interface{}{true, false, false}},
+		{`let xs = [[1]]; let x = xs[0]; [x in xs, [1] in xs]`, []interface{}{true, false}},
+		{`!(1 in [])`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`let a = [1, 2]; let b = [3]; [...a, ...b, 4]`, []interface{}{1, 2, 3, 4}},
+		{`[0, ...1..4, ..."ab", ...[]]`, []interface{}{0, 1, 2, 3, "a", "b"}},
+		{`let a = [1]; let b = [...a]; b[0] = 2; a`, []interface{}{1}},
+		{`let d = {"port": 8080, "tls": false}; let h = {...d, "port": 80}; [h["port"], h["tls"]]`, []interface{}{80, false}},
+		{`let d = {"port": 8080}; {"port": 80, ...d}["port"]`, 8080},
+		{`let d = {"a": 1}; let h = {...d}; h["b"] = 2; [h["b"], d["b"]]`, []interface{}{2, Null}},
+		{`let f = fn(a, b, c = 3) { [a, b, c] }; let args = [1, 2]; f(...args)`, []interface{}{1, 2, 3}},
+		{`let f = fn(a, b, c = 3) { [a, b, c] }; f(...[1], 2, c: 4)`, []interface{}{1, 2, 4}},
+		{`let f = fn(first, ...rest) { rest }; f(...0..5)`, []interface{}{1, 2, 3, 4}},
+		{`push(...[[1]], element: 2)`, []interface{}{1, 2}},
+		{`let g = fn(xs) { let f = fn(a, b) { a - b }; f(...xs) }; g([5, 3])`, 2},
+		{`let [a, ...b] = [0, 0]; [a, ...b] = [1, 2, 3]; b`, []interface{}{2, 3}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestComprehensions(t *testing.T) {
+	tests := []vmTestCase{
+		{`[x * 2 for x in [1, 2, 3]]`, []interface{}{2, 4, 6}},
+		{`[x for x in 0..10 if x % 3 == 0]`, []interface{}{0, 3, 6, 9}},
+		{`[i for i, c in "abc" if c != "b"]`, []interface{}{0, 2}},
+		{`let rows = [[1, 2], [], [3]]; [...row for row in rows if len(row) > 0]`, []interface{}{1, 2, 3}},
+		{`[[x * y for y in 1..3] for x in 1..3]`, []interface{}{[]interface{}{1, 2}, []interface{}{2, 4}}},
+		{`[x for x in []]`, []interface{}{}},
+		{`let n = 10; let f = fn() { [x + n for x in 0..2] }; f()`, []interface{}{10, 11}},
+		{`let fs = [fn() { x } for x in 1..3]; [fs[0](), fs[1]()]`, []interface{}{1, 2}},
+		{`let f = fn() { [if (x == 2) { return x } else { x } for x in 1..4] }; f()`, 2},
+		{`let h = {k: k * k for k in 1..4 if k != 2}; [h[1], h[2], h[3]]`, []interface{}{1, Null, 9}},
+		{`let h = {"a": 1, "b": 2}; let inv = {v: k for k, v in h}; inv[2]`, "b"},
+		{`let x = "outer"; [x for x in [1]]; x`, "outer"},
+		{`let out = []; outer: for (y in 0..3) { out = push(out, [if (x == 1) { continue outer } else { x } for x in 0..3]) } out`, []interface{}{}},
+		{`let out = []; outer: for (y in 0..3) { out = push(out, [if (y == 1) { continue outer } else { x } for x in 0..2]) } out`, []interface{}{[]interface{}{0, 1}, []interface{}{0, 1}}},
+		{`let out = []; outer: for (y in 0..3) { out = push(out, [if (x == y) { break outer } else { x } for x in 0..3]) } out`, []interface{}{}},
+		{`let out = []; for (y in 0..3) { out = push(out, [if (x == y) { continue } else { x } for x in 0..3]) } out`, []interface{}{[]interface{}{1, 2}, []interface{}{0, 2}, []interface{}{0, 1}}},
+		{`let out = []; for (y in 0..3) { out = push(out, if (y == 1) { continue } else { y }) } out`, []interface{}{0, 2}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoopControlInsideExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`let out = []; for (y in 0..3) { out = push(out, 1 + if (y == 1) { continue } else { y }) } out`, []interface{}{1, 3}},
+		{`let out = []; for (y in 0..3) { out = push(out, -if (y == 1) { continue } else { y }) } out`, []interface{}{0, -2}},
+		{`let xs = [10, 20, 30]; let out = []; for (y in 0..3) { out = push(out, xs[if (y == 2) { break } else { y }]) } out`, []interface{}{10, 20}},
+		{`fn() { let h = {"a": if (true) { return 5 } else { 1 }}; 0 }()`, 5},
+		{`fn() { if (if (true) { return "cond" } else { true }) { 1 } else { 2 } }()`, "cond"},
+		{`fn() { match (if (true) { return "subject" } else { 1 }) { _ => 0 } }()`, "subject"},
+		{`fn() { [1, 2][0:if (true) { return 7 } else { 1 }] }()`, 7},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestPipelineOperator(t *testing.T) {
+	tests := []vmTestCase{
+		{`[1, 2, 3] |> len()`, 3},
+		{`let double = fn(x) { x * 2 }; 3 |> double() |> double()`, 12},
+		{`let sub = fn(a, b = 1) { a - b }; [10 |> sub(), 10 |> sub(4), 10 |> sub(b: 3)]`, []interface{}{9, 6, 7}},
+		{`[1, 2] |> push(3) |> tail()`, []interface{}{2, 3}},
+		{`let f = fn(xs) { xs |> len() == 2 }; f([1, 2])`, true},
+		{`[[1, 2] |> len() + 1, [1, 2, 3] |> len() ** 2]`, []interface{}{3, 9}},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
 			input:    `fn() { 1; }(1);`,
-			expected: `wrong number of arguments: want=0, got=1`,
+			expected: `1:12: wrong number of arguments: want=0, got=1`,
 		},
 		{
 			input:    `fn(a) { a; }();`,
-			expected: `wrong number of arguments: want=1, got=0`,
+			expected: `1:13: wrong number of arguments: want=1, got=0`,
 		},
 		{
 			input:    `fn(a, b) { a + b; }(1);`,
-			expected: `wrong number of arguments: want=2, got=1`,
+			expected: `1:20: wrong number of arguments: want=2, got=1`,
+		},
+		{
+			input:    `fn(a, b = 1) { a; }();`,
+			expected: `1:20: wrong number of arguments: want=1 to 2, got=0`,
+		},
+		{
+			input:    `fn(a, b = 1) { a; }(1, 2, 3);`,
+			expected: `1:20: wrong number of arguments: want=1 to 2, got=3`,
+		},
+		{
+			input:    `fn(a, ...rest) { a; }();`,
+			expected: `1:22: wrong number of arguments: want=at least 1, got=0`,
+		},
+		{
+			input:    `fn(a) { a; }(b: 1);`,
+			expected: `1:13: unknown keyword argument b`,
+		},
+		{
+			input:    `fn(a) { a; }(1, a: 2);`,
+			expected: `1:13: argument a passed more than once`,
+		},
+		{
+			input:    `fn(a, b) { a; }(b: 2);`,
+			expected: `1:16: missing argument for parameter a`,
+		},
+		{
+			input:    `print(value: 1);`,
+			expected: `1:6: builtin function doesn't accept keyword arguments`,
+		},
+		{
+			input:    `early(); fn early() { 1 }`,
+			expected: `1:6: calling a function before its declaration`,
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestRuntimeErrorPositions(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `let a = 1;
+			let b = "two";
+			a + b;`,
+			expected: `3:6: unsupported types for binary operation: INTEGER STRING`,
+		},
+		{
+			input: `let f = fn(x) {
+				return -x;
+			};
+			f(true);`,
+			expected: `2:12: unsupported type for negation: BOOLEAN`,
+		},
+		{
+			input: `for (let i = 0; i < 3; i++) {
+				i + "x";
+			}`,
+			expected: `2:7: unsupported types for binary operation: INTEGER STRING`,
+		},
+		{
+			input:    `let x = 1.5; x & 1`,
+			expected: `1:16: unsupported types for bitwise operation: FLOAT INTEGER`,
+		},
+		{
+			input:    `1 << -1`,
+			expected: `1:3: negative shift count: -1`,
+		},
+		{
+			input:    `let n = -1; 2 ** n`,
+			expected: `1:15: negative exponent: -1`,
+		},
+		{
+			input:    `10 ** 19`,
+			expected: `1:4: integer overflow: 10 ** 19`,
+		},
+		{
+			input:    `2 ** 0.5`,
+			expected: `1:3: unsupported types for binary operation: INTEGER FLOAT`,
+		},
+		{
+			input:    `~"a"`,
+			expected: `1:1: unsupported type for bitwise negation: STRING`,
+		},
+		{
+			input:    `let a = [1]; a[1] = 2`,
+			expected: `1:19: index out of range: 1`,
+		},
+		{
+			input:    `[1, 2]["a":]`,
+			expected: `1:7: slice bounds must be INTEGER, got STRING`,
+		},
+		{
+			input:    `5[1:]`,
+			expected: `1:2: slice operator not supported: INTEGER`,
+		},
+		{
+			input:    `let s = "abc"; 1 in s`,
+			expected: `1:18: unknown operator: INTEGER in STRING`,
+		},
+		{
+			input:    `[1] in {}`,
+			expected: `1:5: unusable as hash key: ARRAY`,
+		},
+		{
+			input:    `let a = 1; [0, ...a]`,
+			expected: `1:16: cannot spread INTEGER, expected ARRAY, RANGE or STRING`,
+		},
+		{
+			input:    `let f = fn(a) { a }; f(...[1, 2])`,
+			expected: `1:23: wrong number of arguments: want=1, got=2`,
+		},
+		{
+			input:    `{"a": 1, ...[1]}`,
+			expected: `1:10: cannot spread ARRAY into a hash, expected HASH`,
+		},
+		{
+			input:    `let n = 5; [x for x in n]`,
+			expected: `1:24: cannot iterate over INTEGER`,
+		},
+		{
+			input:    `{[x]: x for x in 1..3}`,
+			expected: `1:2: unusable as hash key: ARRAY`,
+		},
+		{
+			input:    `0..2:0`,
+			expected: `1:2: range step cannot be zero`,
+		},
+		{
+			input:    `let a = "z"; a..2`,
+			expected: `1:15: range bounds must be INTEGER, got STRING`,
+		},
+		{
+			input:    `len(-9223372036854775807..9223372036854775807)`,
+			expected: `1:25: range -9223372036854775807..9223372036854775807 is too long, it has more than 9223372036854775807 integers`,
+		},
+		{
+			input:    `[...0..9223372036854775807]`,
+			expected: `1:2: range 0..9223372036854775807 is too long to build an array from, at most 16777216 integers allowed`,
+		},
+		{
+			input: `let a = 1;
+			for (x in a) { x }`,
+			expected: `2:14: cannot iterate over INTEGER`,
 		},
 	}
 
@@ -442,6 +967,12 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []vmTestCase{
 		{`len("")`, 0},
+		{
+			`push(0..9223372036854775807, 1)`,
+			&object.Error{
+				Message: "range 0..9223372036854775807 is too long to build an array from, at most 16777216 integers allowed",
+			},
+		},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
 		{
@@ -477,6 +1008,14 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`tail([1, 2, 3])`, []int{2, 3}},
 		{`tail([])`, Null},
 		{`push([], 1)`, []int{1}},
+		{`len(0..10:3)`, 4},
+		{`len(0..1000000000000)`, 1000000000000},
+		{`len(5..1)`, 0},
+		{`first(3..6)`, 3},
+		{`last(0..10:3)`, 9},
+		{`last(0..0)`, Null},
+		{`tail(0..10:3)`, &object.Range{Start: 3, End: 10, Step: 3}},
+		{`push(1..3, 9)`, []int{1, 2, 9}},
 		{
 			`push(1, 1)`,
 			&object.Error{
@@ -670,6 +1209,9 @@ func TestFloatingPointNumbers(t *testing.T) {
 		{"0.1 * 2.0", 0.2},
 		{"0.1 / 2.0", 0.05},
 		{"0.1 + 0.1 + 0.1", 0.3},
+		{"2.0 ** 0.5", 1.4142135623730951},
+		{"4.0 ** -1.0", 0.25},
+		{"2.0 ** 3.0 ** 2.0", 512.0},
 	}
 
 	runVmTests(t, tests)
@@ -746,6 +1288,26 @@ func TestForLoop(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestForInLoop(t *testing.T) {
+	tests := []vmTestCase{
+		{`let s = 0; for (x in [1, 2, 3]) { s = s + x } s`, 6},
+		{`let r = []; for (i, x in ["a", "b"]) { r = push(r, "${i}${x}") } r`, []interface{}{"0a", "1b"}},
+		{`let r = []; for (k in {"b": 2, "a": 1}) { r = push(r, k) } r`, []interface{}{"a", "b"}},
+		{`let s = 0; for (k, v in {"a": 1, "b": 2, "c": 3}) { if (k == "b") { continue } s = s + v } s`, 4},
+		{`let r = []; for (ch in "héllo") { if (ch == "l") { break } r = push(r, ch) } r`, []interface{}{"h", "é"}},
+		{`let r = []; for (i, ch in "ab") { r = push(r, i) } r`, []interface{}{0, 1}},
+		{`let n = 0; for (x in []) { n = n + 1 } n`, 0},
+		{`let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x * 10 }) } [fs[0](), fs[1]()]`, []interface{}{10, 20}},
+		{`fn find(arr, target) { for (i, x in arr) { if (x == target) { return i } } -1 } [find([5, 6, 7], 7), find([5], 9)]`, []interface{}{2, -1}},
+		{`fn grid() { let c = 0; for (i in [1, 2, 3]) { for (j in [1, 2]) { c = c + i * j } } c } grid()`, 18},
+		{`fn nested() { let r = []; for (a in [1, 2]) { for (b in [3]) { for (c in [5]) { r = push(r, a * b * c) } } } r } nested()`, []interface{}{15, 30}},
+		{`fn sum(node) { let s = node["v"]; for (c in node["kids"]) { s = s + sum(c) } s } sum({"v": 1, "kids": [{"v": 2, "kids": []}]})`, 3},
+		{`let g = fn(x) { let h = fn() { let s = 0; for (y in [1, 2]) { s = s + x * y } s }; h() }; g(5)`, 15},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestCompoundAssignment(t *testing.T) {
 	tests := []vmTestCase{
 		{"let a = 1; a += 2; a", 3},
@@ -753,6 +1315,8 @@ func TestCompoundAssignment(t *testing.T) {
 		{"let a = 1; a *= 2; a", 2},
 		{"let a = 4; a /= 2; a", 2},
 		{"let a = 5; a %= 2; a", 1},
+		{"let a = 3; a **= 2; a", 9},
+		{"let a = 2.0; a **= -1.0; a", 0.5},
 		{"let a = 1; a += 2 * 3; a", 7},
 		{"let a = 2; a *= 5 + 2; a", 14},
 	}
@@ -760,6 +1324,82 @@ func TestCompoundAssignment(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestLabeledLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+			let pairs = [];
+			outer: for (let i = 0; i < 3; i += 1) {
+				for (let j = 0; j < 3; j += 1) {
+					if (j > i) {
+						continue outer;
+					}
+					if (i == 2) {
+						break outer;
+					}
+					pairs = push(pairs, [i, j]);
+				}
+			}
+			pairs;
+			`,
+			expected: []interface{}{[]interface{}{0, 0}, []interface{}{1, 0}, []interface{}{1, 1}},
+		},
+		{
+			input: `
+			let found = null;
+			rows: for (r, row in [[1, 2], [3, 4], [5, 6]]) {
+				for (x in row) {
+					while (true) {
+						if (x == 4) {
+							found = [r, x];
+							break rows;
+						}
+						break;
+					}
+				}
+			}
+			found;
+			`,
+			expected: []interface{}{1, 4},
+		},
+		{
+			input: `
+			let count = 0;
+			l: for (i in 0..3) {
+				l: for (j in 0..3) {
+					if (j == 1) {
+						continue l;
+					}
+					count += 1;
+				}
+			}
+			count;
+			`,
+			expected: 6,
+		},
+		{
+			input: `
+			let f = fn() {
+				let sum = 0;
+				outer: while (true) {
+					for (x in 1..10) {
+						sum += x;
+						if (x == 3) {
+							break outer;
+						}
+					}
+				}
+				sum;
+			};
+			f() + 1;
+			`,
+			expected: 7,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestWhileLoop(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -858,7 +1498,8 @@ func TestArrayReassignment(t *testing.T) {
 		{"let a = [1, 2, 3]; a[0] = 4; a[1]", 2},
 		{"let a = [1, 2, 3]; a[0] = 4; a[2]", 3},
 		{"let a = [1, 2, 3]; a[0] = 4; a[3]", Null},
-		{"let a = [1, 2, 3]; a[0] = 4; a[-1]", Null},
+		{"let a = [1, 2, 3]; a[0] = 4; a[-1]", 3},
+		{"let a = [1, 2, 3]; a[-1] = 4; a", []int{1, 2, 4}},
 		{"let a = [1, 2, 3]; a[0] = 4; a[1] = 5; a[2] = 6; a", []int{4, 5, 6}},
 	}
 
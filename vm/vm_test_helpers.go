@@ -77,6 +77,19 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 		if err != nil {
 			t.Errorf("testIntArray failed: %s", err)
 		}
+	case []interface{}:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array: %T (%+v)", actual, actual)
+			return
+		}
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+			return
+		}
+		for i, expectedElem := range expected {
+			testExpectedObject(t, expectedElem, array.Elements[i])
+		}
 	case map[object.HashKey]int64:
 		err := testIntHash(expected, actual)
 		if err != nil {
@@ -92,6 +105,15 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 			t.Errorf("wrong error message. expected=%q, got=%q",
 				expected.Message, errObj.Message)
 		}
+	case *object.Range:
+		rng, ok := actual.(*object.Range)
+		if !ok {
+			t.Errorf("object is not Range: %T (%+v)", actual, actual)
+			return
+		}
+		if *rng != *expected {
+			t.Errorf("wrong range. want=%s, got=%s", expected.Inspect(), rng.Inspect())
+		}
 	case float64:
 		err := testFloatObject(expected, actual)
 		if err != nil {